@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/app"
+	"github.com/nhassl3/url-saver/internals/config"
+	"github.com/nhassl3/url-saver/internals/domain/services/apikeys"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+)
+
+var (
+	create         string
+	userID, revoke int64
+	list           bool
+)
+
+func init() {
+	flag.Int64Var(&userID, "user-id", 0, "ID of the user owning the keys")
+	flag.StringVar(&create, "create", "", "Create new api key with the given name")
+	flag.BoolVar(&list, "list", false, "List api keys of the user")
+	flag.Int64Var(&revoke, "revoke", 0, "Revoke api key with the given ID")
+}
+
+// usageError reports invalid arguments the way flag does
+func usageError(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	flag.Usage()
+	os.Exit(2)
+}
+
+func main() {
+	// keys are kept in the storage of the server, so it is loaded from
+	// the same config, --config or CONFIG_PATH
+	cfg := config.MustLoad()
+
+	if userID <= 0 {
+		usageError("user ID is required")
+	}
+	if create == "" && revoke == 0 && !list {
+		usageError("one of --create, --revoke or --list is required")
+	}
+	if cfg.Storage.Driver == "memory" {
+		usageError("memory storage keeps no api keys, configure sqlite or postgres")
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	storage := app.MustLoadStorage(log, cfg)
+	defer func() {
+		if err := app.CloseStorage(storage); err != nil {
+			log.Error("failed to close storage", sl.Err(err))
+		}
+	}()
+
+	keys := apikeys.NewApiKeys(log, storage, storage, storage)
+	ctx := userctx.WithUserID(context.Background(), userID)
+
+	switch {
+	case create != "":
+		key, keyID, err := keys.Create(ctx, create)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Created api key %d, it is shown only once:\n%s\n", keyID, key)
+	case revoke != 0:
+		if err := keys.Revoke(ctx, revoke); err != nil {
+			panic(err)
+		}
+		fmt.Println("Revoked api key", revoke)
+	case list:
+		userKeys, err := keys.List(ctx)
+		if err != nil {
+			panic(err)
+		}
+		for _, key := range userKeys {
+			state := "active"
+			if key.RevokedAt != nil {
+				state = "revoked at " + key.RevokedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%d\t%s...\t%s\t%s\t%s\n",
+				key.ID, key.Prefix, key.Name, key.CreatedAt.Format(time.RFC3339), state)
+		}
+	}
+}
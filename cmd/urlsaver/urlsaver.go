@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -9,6 +10,7 @@ import (
 	"github.com/nhassl3/url-saver/internals/app"
 	"github.com/nhassl3/url-saver/internals/config"
 	"github.com/nhassl3/url-saver/internals/lib/logger"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
 )
 
 var (
@@ -27,19 +29,31 @@ func main() {
 	log.Info("Starting URL Saver", slog.Int("port", cfg.GRPC.Port))
 
 	// loading configuration for this service and other too
-	application := app.NewApp(
-		log,
-		cfg.GRPC.Port,
-		cfg.HTTP.UrlShortener.MaxRetires,
-		cfg.StoragePath,
-		cfg.HTTP.UrlShortener.BaseUrl,
-		cfg.HTTP.UrlShortener.Timeout,
-	)
+	application := app.NewApp(log, cfg)
 
 	go application.GRPCServer.MustStart()
+	go application.HTTPServer.MustStart()
+	go application.Janitor.MustStart()
+	go application.VisitRecorder.MustStart()
+	go application.MetricsServer.MustStart()
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
 
-	log.Info("URL Server stopped", slog.String("signal", (<-sig).String()))
+	stopSignal := <-sig
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.Redirect.Timeout)
+	defer cancel()
+
+	application.HTTPServer.Stop(ctx)
+	application.MetricsServer.Stop(ctx)
+	application.VisitRecorder.Stop()
+	application.GRPCServer.Stop()
+	application.Janitor.Stop()
+
+	if err := application.Close(); err != nil {
+		log.Error("failed to close storage", sl.Err(err))
+	}
+
+	log.Info("URL Server stopped", slog.String("signal", stopSignal.String()))
 }
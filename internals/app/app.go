@@ -1,37 +1,255 @@
 package app
 
 import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
 	"log/slog"
-	"time"
 
 	"github.com/nhassl3/url-saver/internals/app/grpcapp"
+	"github.com/nhassl3/url-saver/internals/app/httpapp"
+	"github.com/nhassl3/url-saver/internals/app/janitorapp"
+	"github.com/nhassl3/url-saver/internals/app/metricsapp"
 	urlshortener "github.com/nhassl3/url-saver/internals/clients/urlshortener/http"
+	localshortener "github.com/nhassl3/url-saver/internals/clients/urlshortener/local"
+	"github.com/nhassl3/url-saver/internals/config"
+	"github.com/nhassl3/url-saver/internals/domain/services/analytics"
+	"github.com/nhassl3/url-saver/internals/domain/services/apikeys"
+	"github.com/nhassl3/url-saver/internals/domain/services/urlnorm"
 	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+	"github.com/nhassl3/url-saver/internals/domain/services/visits"
+	urlSavergrpc "github.com/nhassl3/url-saver/internals/grpc/urlsaver"
+	"github.com/nhassl3/url-saver/internals/lib/aliasgen"
+	"github.com/nhassl3/url-saver/internals/lib/jwt"
+	"github.com/nhassl3/url-saver/internals/lib/pagetoken"
+	"github.com/nhassl3/url-saver/internals/storage/cache"
+	"github.com/nhassl3/url-saver/internals/storage/memory"
+	"github.com/nhassl3/url-saver/internals/storage/postgres"
 	"github.com/nhassl3/url-saver/internals/storage/sqlite"
 )
 
+const (
+	shortenerLocal  = "local"
+	shortenerRemote = "remote"
+
+	janitorDelete  = "delete"
+	janitorArchive = "archive"
+
+	storageSqlite   = "sqlite"
+	storagePostgres = "postgres"
+	storageMemory   = "memory"
+)
+
+// Storage is implemented by every storage backend
+type Storage interface {
+	urlsaver.SaverUrl
+	urlsaver.ProviderUrl
+	urlsaver.UpdaterUrl
+	apikeys.SaverApiKey
+	apikeys.ProviderApiKey
+	apikeys.RevokerApiKey
+	visits.SaverVisit
+	analytics.ProviderVisit
+	janitorapp.UrlPurger
+}
+
 type App struct {
 	GRPCServer *grpcapp.App
+	HTTPServer *httpapp.App
+	Janitor    *janitorapp.App
+	// MetricsServer serves counters on the admin address, apart from HTTPServer
+	MetricsServer *metricsapp.App
+	// VisitRecorder saves visits of redirects, it must be stopped after HTTPServer
+	VisitRecorder *visits.Recorder
+
+	storage Storage
 }
 
-func NewApp(
-	log *slog.Logger,
-	gRPCPort,
-	maxRetries int,
-	storagePath,
-	baseUrlShortenerUrl string,
-	timeout time.Duration,
-) *App {
-	storage, err := sqlite.NewStorage(storagePath)
+// NewApp builds the services and servers configured by cfg. It panics on
+// invalid configuration
+func NewApp(log *slog.Logger, cfg *config.Config) *App {
+	storage := MustLoadStorage(log, cfg)
+
+	aliasGenerator, err := aliasgen.New(cfg.Alias.Generator, cfg.Alias.Alphabet, cfg.Alias.Length, cfg.Alias.Words)
+	if err != nil {
+		panic(err)
+	}
+
+	shortenerCfg := cfg.HTTP.UrlShortener
+
+	var urlShortenerObject urlsaver.Shortener
+	switch shortenerCfg.Mode {
+	case shortenerLocal:
+		urlShortenerObject = localshortener.NewShortener(log, aliasGenerator)
+	case shortenerRemote:
+		if shortenerCfg.BaseUrl == "" {
+			panic("url shortener base url is required in remote mode")
+		}
+		urlShortenerObject = urlshortener.NewClient(
+			log, shortenerCfg.Timeout, shortenerCfg.MaxRetires, shortenerCfg.BaseUrl,
+		)
+	default:
+		panic(fmt.Sprintf("unknown url shortener mode %q", shortenerCfg.Mode))
+	}
+
+	tokenVerifier, err := jwt.NewVerifier(cfg.Auth.HMACSecret, cfg.Auth.RSAPublicKeyPath, cfg.Auth.Issuer, cfg.Auth.Audience)
 	if err != nil {
 		panic(err)
 	}
 
-	urlShortenerObject := urlshortener.NewClient(log, timeout, maxRetries, baseUrlShortenerUrl)
+	pageTokenSecret := []byte(cfg.Pagination.PageTokenSecret)
+	if len(pageTokenSecret) == 0 {
+		log.Warn("page token secret is not set, page tokens will not survive restart")
 
-	urlSaverObj := urlsaver.NewUrlSaver(log, storage, storage, storage)
+		pageTokenSecret = make([]byte, 32)
+		if _, err := rand.Read(pageTokenSecret); err != nil {
+			panic(err)
+		}
+	}
+
+	urlNormalizer := urlnorm.NewNormalizer(cfg.URL.Schemes, cfg.URL.MaxLength, cfg.URL.AllowPrivate)
+
+	// alias lookups of the service go through the cache, so do its writes
+	// to evict the aliases they change
+	var (
+		urlStorage cache.Storage = storage
+		cacheStats metricsapp.CacheStats
+	)
+	if cfg.Cache.Size > 0 {
+		urlCache := cache.NewUrlCache(storage, cfg.Cache.Size, cfg.Cache.TTL, cfg.Cache.NegativeTTL)
+
+		urlStorage, cacheStats = urlCache, urlCache
+	}
+
+	urlSaverObj := urlsaver.NewUrlSaver(
+		log, urlStorage, urlStorage, urlStorage, urlShortenerObject, urlNormalizer,
+		pagetoken.NewCodec(pageTokenSecret), aliasGenerator,
+		urlsaver.Options{
+			MaxPageSize:     cfg.Pagination.MaxPageSize,
+			AliasRetries:    cfg.Alias.Retries,
+			ReservedAliases: cfg.Alias.Reserved,
+			Dedupe:          cfg.URL.Dedupe,
+		},
+	)
+
+	apiKeysObj := apikeys.NewApiKeys(log, storage, storage, storage)
+
+	analyticsObj := analytics.NewAnalytics(log, storage, storage)
+
+	if cfg.Janitor.Interval <= 0 || cfg.Janitor.BatchSize <= 0 {
+		panic("janitor interval and batch size must be positive")
+	}
+
+	var archiveExpired bool
+	switch cfg.Janitor.Mode {
+	case janitorArchive:
+		archiveExpired = true
+	case janitorDelete:
+	default:
+		panic(fmt.Sprintf("unknown janitor mode %q", cfg.Janitor.Mode))
+	}
+
+	if cfg.Visits.QueueSize <= 0 || cfg.Visits.BatchSize <= 0 || cfg.Visits.FlushInterval <= 0 {
+		panic("visits queue size, batch size and flush interval must be positive")
+	}
+
+	ipSalt := []byte(cfg.Visits.IPSalt)
+	if len(ipSalt) == 0 {
+		log.Warn("visits ip salt is not set, visitors will be counted again after restart")
+
+		ipSalt = make([]byte, 32)
+		if _, err := rand.Read(ipSalt); err != nil {
+			panic(err)
+		}
+	}
+
+	visitRecorder := visits.NewRecorder(
+		log, storage, ipSalt, cfg.Visits.QueueSize, cfg.Visits.BatchSize, cfg.Visits.FlushInterval,
+	)
+
+	redirectCfg := cfg.HTTP.Redirect
 
 	return &App{
-		GRPCServer: grpcapp.NewApp(log, gRPCPort, urlSaverObj, urlShortenerObject),
+		GRPCServer: grpcapp.NewApp(
+			log, cfg.GRPC.Port, urlSaverObj, analyticsObj, apiKeysObj, tokenVerifier, apiKeysObj, cfg.Auth.ExemptMethods,
+		),
+		HTTPServer: httpapp.NewApp(
+			log, redirectCfg.Port, redirectCfg.StatusCode, redirectCfg.Timeout, urlSaverObj, visitRecorder, !cfg.GlobalAliases,
+			urlSavergrpc.NewServerAPI(urlSaverObj), tokenVerifier, apiKeysObj,
+		),
+		Janitor: janitorapp.NewApp(
+			log, storage, cfg.Janitor.Interval, archiveExpired, cfg.Janitor.TrashRetention, cfg.Janitor.BatchSize,
+		),
+		VisitRecorder: visitRecorder,
+		MetricsServer: metricsapp.NewApp(log, cfg.Metrics.Address, cacheStats),
+		storage:       storage,
+	}
+}
+
+// Close closes the storage, it must be called after all servers, the janitor
+// and the visit recorder are stopped
+func (a *App) Close() error {
+	return CloseStorage(a.storage)
+}
+
+// MustLoadStorage opens the storage selected by cfg.Storage.Driver. It panics
+// on invalid configuration or when the storage can't be opened
+func MustLoadStorage(log *slog.Logger, cfg *config.Config) Storage {
+	var (
+		storage Storage
+		err     error
+	)
+	switch cfg.Storage.Driver {
+	case storageSqlite:
+		if cfg.StoragePath == "" {
+			panic("storage path is required by sqlite storage")
+		}
+		storage, err = loadSqlite(log, cfg)
+	case storagePostgres:
+		if cfg.Storage.DSN == "" {
+			panic("storage dsn is required by postgres storage")
+		}
+		storage, err = postgres.NewStorage(cfg.Storage.DSN, cfg.GlobalAliases)
+	case storageMemory:
+		log.Warn("memory storage is used, everything will be lost on restart")
+
+		storage = memory.NewStorage(cfg.GlobalAliases)
+	default:
+		panic(fmt.Sprintf("unknown storage driver %q", cfg.Storage.Driver))
+	}
+	if err != nil {
+		panic(err)
 	}
+
+	return storage
+}
+
+// loadSqlite opens sqlite storage and hashes urls saved before url_hash was
+// added, until then they are not deduplicated
+func loadSqlite(log *slog.Logger, cfg *config.Config) (*sqlite.Storage, error) {
+	s, err := sqlite.NewStorage(cfg.StoragePath, cfg.GlobalAliases)
+	if err != nil {
+		return nil, err
+	}
+
+	filled, err := s.BackfillUrlHashes(context.Background())
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	if filled > 0 {
+		log.Info("hashes of saved urls are filled", slog.Int64("count", filled))
+	}
+
+	return s, nil
+}
+
+// CloseStorage closes the storage if it holds any connections
+func CloseStorage(storage Storage) error {
+	if closer, ok := storage.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
 }
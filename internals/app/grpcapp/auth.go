@@ -0,0 +1,160 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/nhassl3/url-saver/internals/domain/services/apikeys"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorizationMetadataKey = "authorization"
+	apiKeyMetadataKey        = "x-api-key"
+	bearerScheme             = "bearer"
+
+	errNoToken       = "bearer token is not provided"
+	errInvalidToken  = "bearer token is invalid"
+	errInvalidApiKey = "api key is invalid"
+	errAuthFailed    = "failed to authenticate"
+)
+
+type TokenVerifier interface {
+	Verify(token string) (userID int64, err error)
+}
+
+type ApiKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (userID int64, err error)
+}
+
+// AuthInterceptor authenticates requests by the bearer token from the
+// authorization metadata and puts ID of the user into the request context
+type AuthInterceptor struct {
+	log           *slog.Logger
+	verifier      TokenVerifier
+	exemptMethods map[string]struct{}
+}
+
+func NewAuthInterceptor(log *slog.Logger, verifier TokenVerifier, exemptMethods []string) *AuthInterceptor {
+	exempt := make(map[string]struct{}, len(exemptMethods))
+	for _, method := range exemptMethods {
+		exempt[method] = struct{}{}
+	}
+
+	return &AuthInterceptor{
+		log:           log,
+		verifier:      verifier,
+		exemptMethods: exempt,
+	}
+}
+
+func (i *AuthInterceptor) Unary(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if _, ok := i.exemptMethods[info.FullMethod]; ok {
+		return handler(ctx, req)
+	}
+
+	// already authenticated by the api key
+	if _, ok := userctx.UserID(ctx); ok {
+		return handler(ctx, req)
+	}
+
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, errNoToken)
+	}
+
+	userID, err := i.verifier.Verify(token)
+	if err != nil {
+		i.log.Debug("request rejected",
+			slog.String("method", info.FullMethod),
+			sl.Err(err),
+		)
+		return nil, status.Error(codes.Unauthenticated, errInvalidToken)
+	}
+
+	return handler(userctx.WithUserID(ctx, userID), req)
+}
+
+// ApiKeyInterceptor authenticates requests carrying x-api-key metadata and
+// puts ID of the key owner into the request context. Requests without
+// the key are passed on to the next interceptor
+type ApiKeyInterceptor struct {
+	log           *slog.Logger
+	authenticator ApiKeyAuthenticator
+}
+
+func NewApiKeyInterceptor(log *slog.Logger, authenticator ApiKeyAuthenticator) *ApiKeyInterceptor {
+	return &ApiKeyInterceptor{
+		log:           log,
+		authenticator: authenticator,
+	}
+}
+
+func (i *ApiKeyInterceptor) Unary(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	values := md.Get(apiKeyMetadataKey)
+	if len(values) == 0 {
+		return handler(ctx, req)
+	}
+
+	userID, err := i.authenticator.Authenticate(ctx, values[0])
+	if err != nil {
+		// storage failures are not the fault of the key, clients must not drop it
+		if !errors.Is(err, apikeys.ErrInvalidApiKey) {
+			i.log.Error("failed to authenticate api key",
+				slog.String("method", info.FullMethod),
+				sl.Err(err),
+			)
+			return nil, status.Error(codes.Internal, errAuthFailed)
+		}
+
+		i.log.Debug("request rejected",
+			slog.String("method", info.FullMethod),
+			sl.Err(err),
+		)
+		return nil, status.Error(codes.Unauthenticated, errInvalidApiKey)
+	}
+
+	return handler(userctx.WithApiKey(userctx.WithUserID(ctx, userID)), req)
+}
+
+// bearerToken returns token from the "authorization: Bearer <token>" metadata
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	values := md.Get(authorizationMetadataKey)
+	if len(values) == 0 {
+		return "", false
+	}
+
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, bearerScheme) || token == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
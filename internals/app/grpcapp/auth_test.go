@@ -0,0 +1,140 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/nhassl3/url-saver/internals/domain/services/apikeys"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+	"github.com/nhassl3/url-saver/internals/storage/memory"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	tokenUserID  = 7
+	apiKeyUserID = 9
+
+	validToken    = "valid-token"
+	method        = "/UrlSaver.UrlSaver/Get"
+	exemptMethod  = "/grpc.health.v1.Health/Check"
+	failingApiKey = "us_failing"
+)
+
+type stubVerifier struct{}
+
+func (stubVerifier) Verify(token string) (int64, error) {
+	if token != validToken {
+		return 0, errors.New("invalid token")
+	}
+
+	return tokenUserID, nil
+}
+
+// failingAuthenticator authenticates keys by the service, failingApiKey
+// fails like an unavailable storage does
+type failingAuthenticator struct {
+	*apikeys.ApiKeys
+}
+
+func (a failingAuthenticator) Authenticate(ctx context.Context, key string) (int64, error) {
+	if key == failingApiKey {
+		return 0, errors.New("storage is unavailable")
+	}
+
+	return a.ApiKeys.Authenticate(ctx, key)
+}
+
+// caller is what the handler sees of the authenticated caller
+type caller struct {
+	userID   int64
+	byApiKey bool
+}
+
+func TestAuthInterceptors(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	s := memory.NewStorage(true)
+	keys := apikeys.NewApiKeys(log, s, s, s)
+	ownerCtx := userctx.WithUserID(context.Background(), apiKeyUserID)
+	activeKey, _, err := keys.Create(ownerCtx, "active")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedKey, revokedID, err := keys.Create(ownerCtx, "revoked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = keys.Revoke(ownerCtx, revokedID); err != nil {
+		t.Fatal(err)
+	}
+
+	apiKeyInterceptor := NewApiKeyInterceptor(log, failingAuthenticator{keys})
+	authInterceptor := NewAuthInterceptor(log, stubVerifier{}, []string{exemptMethod})
+
+	tests := []struct {
+		name   string
+		method string
+		md     metadata.MD
+		// want is the caller seen by the handler when wantCode is OK
+		want     caller
+		wantCode codes.Code
+	}{
+		{name: "bearer token", md: metadata.Pairs("authorization", "Bearer "+validToken), want: caller{userID: tokenUserID}},
+		{name: "lowercase scheme", md: metadata.Pairs("authorization", "bearer "+validToken), want: caller{userID: tokenUserID}},
+		{name: "no credentials", md: metadata.MD{}, wantCode: codes.Unauthenticated},
+		{name: "invalid token", md: metadata.Pairs("authorization", "Bearer forged"), wantCode: codes.Unauthenticated},
+		{name: "other scheme", md: metadata.Pairs("authorization", "Basic "+validToken), wantCode: codes.Unauthenticated},
+		{name: "empty token", md: metadata.Pairs("authorization", "Bearer "), wantCode: codes.Unauthenticated},
+		{name: "api key", md: metadata.Pairs("x-api-key", activeKey), want: caller{userID: apiKeyUserID, byApiKey: true}},
+		{
+			name: "api key before token",
+			md:   metadata.Pairs("x-api-key", activeKey, "authorization", "Bearer "+validToken),
+			want: caller{userID: apiKeyUserID, byApiKey: true},
+		},
+		{
+			name:     "invalid api key with valid token",
+			md:       metadata.Pairs("x-api-key", "us_unknown", "authorization", "Bearer "+validToken),
+			wantCode: codes.Unauthenticated,
+		},
+		{name: "revoked api key", md: metadata.Pairs("x-api-key", revokedKey), wantCode: codes.Unauthenticated},
+		{name: "api key of other service", md: metadata.Pairs("x-api-key", "sk_"+activeKey[3:]), wantCode: codes.Unauthenticated},
+		{name: "api key storage failure", md: metadata.Pairs("x-api-key", failingApiKey), wantCode: codes.Internal},
+		{name: "exempt method", method: exemptMethod, md: metadata.MD{}},
+		{name: "exempt method with invalid token", method: exemptMethod, md: metadata.Pairs("authorization", "Bearer forged")},
+		{name: "exempt method with invalid api key", method: exemptMethod, md: metadata.Pairs("x-api-key", "us_unknown"), wantCode: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &grpc.UnaryServerInfo{FullMethod: method}
+			if tt.method != "" {
+				info.FullMethod = tt.method
+			}
+
+			var got caller
+			handler := func(ctx context.Context, _ any) (any, error) {
+				got.userID, _ = userctx.UserID(ctx)
+				got.byApiKey = userctx.ByApiKey(ctx)
+				return "ok", nil
+			}
+			// the order of the server chain
+			chain := func(ctx context.Context, req any) (any, error) {
+				return apiKeyInterceptor.Unary(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+					return authInterceptor.Unary(ctx, req, info, handler)
+				})
+			}
+
+			_, err := chain(metadata.NewIncomingContext(context.Background(), tt.md), nil)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v (%v), want %v", code, err, tt.wantCode)
+			}
+			if tt.wantCode == codes.OK && got != tt.want {
+				t.Errorf("caller = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"log/slog"
 	"net"
 
-	urlshortener "github.com/nhassl3/url-saver/internals/clients/urlshortener/http"
+	"github.com/nhassl3/url-saver/internals/domain/services/analytics"
+	"github.com/nhassl3/url-saver/internals/domain/services/apikeys"
 	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+	analyticsgrpc "github.com/nhassl3/url-saver/internals/grpc/analytics"
+	apikeysgrpc "github.com/nhassl3/url-saver/internals/grpc/apikeys"
 	urlSavergrpc "github.com/nhassl3/url-saver/internals/grpc/urlsaver"
 	"google.golang.org/grpc"
 )
@@ -22,10 +25,19 @@ type App struct {
 func NewApp(log *slog.Logger,
 	gRPCPort int,
 	urlSaverObj *urlsaver.UrlSaver,
-	urlShortenerClient *urlshortener.Client) *App {
-	gRPCServer := grpc.NewServer()
+	analyticsObj *analytics.Analytics,
+	apiKeysObj *apikeys.ApiKeys,
+	tokenVerifier TokenVerifier,
+	apiKeyAuthenticator ApiKeyAuthenticator,
+	exemptMethods []string) *App {
+	apiKeyInterceptor := NewApiKeyInterceptor(log, apiKeyAuthenticator)
+	authInterceptor := NewAuthInterceptor(log, tokenVerifier, exemptMethods)
 
-	urlSavergrpc.Register(gRPCServer, urlSaverObj, urlShortenerClient)
+	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(apiKeyInterceptor.Unary, authInterceptor.Unary))
+
+	urlSavergrpc.Register(gRPCServer, urlSaverObj)
+	analyticsgrpc.Register(gRPCServer, analyticsObj)
+	apikeysgrpc.Register(gRPCServer, apiKeysObj)
 
 	return &App{
 		gRPCServer: gRPCServer,
@@ -0,0 +1,76 @@
+package httpapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/http/redirect"
+	"github.com/nhassl3/url-saver/internals/http/rest"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const opStart = "httpapp.MustStart"
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+}
+
+func NewApp(log *slog.Logger,
+	httpPort int,
+	redirectCode int,
+	timeout time.Duration,
+	urlGetter redirect.UrlGetter,
+	visitRecorder redirect.VisitRecorder,
+	perUserAliases bool,
+	urlSaverServer rest.UrlSaverServer,
+	tokenVerifier rest.TokenVerifier,
+	apiKeyAuthenticator rest.ApiKeyAuthenticator) *App {
+	switch redirectCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		panic(fmt.Sprintf("unsupported redirect status code %d", redirectCode))
+	}
+
+	mux := http.NewServeMux()
+
+	redirect.Register(mux, log, urlGetter, visitRecorder, redirectCode, perUserAliases)
+	rest.Register(mux, log, urlSaverServer, tokenVerifier, apiKeyAuthenticator)
+
+	return &App{
+		httpServer: &http.Server{
+			Handler:      mux,
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+		},
+		port: httpPort,
+		log:  log,
+	}
+}
+
+func (app *App) MustStart() {
+	log := app.log.With(slog.String("op", opStart), slog.Int("port", app.port))
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", app.port))
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", opStart, err))
+	}
+
+	log.Info("HTTP server started", slog.String("address", l.Addr().String()))
+
+	if err := app.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(fmt.Errorf("%s: %w", opStart, err))
+	}
+}
+
+func (app *App) Stop(ctx context.Context) {
+	if err := app.httpServer.Shutdown(ctx); err != nil {
+		app.log.Error("failed to stop HTTP server", sl.Err(err))
+	}
+}
@@ -0,0 +1,122 @@
+package janitorapp
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const opPurge = "janitorapp.purge"
+
+type UrlPurger interface {
+	PurgeExpiredUrls(ctx context.Context, archive bool, limit int) (purged int64, err error)
+	PurgeDeletedUrls(ctx context.Context, before time.Time, limit int) (purged int64, err error)
+}
+
+// App periodically removes expired urls and urls kept in the trash longer
+// than the retention period. They are deleted in batches, so the storage
+// is not locked for long
+type App struct {
+	log            *slog.Logger
+	purger         UrlPurger
+	interval       time.Duration
+	archive        bool
+	trashRetention time.Duration
+	batchSize      int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewApp creates janitor running every interval. With archive expired urls
+// are moved to the archive instead of being deleted
+func NewApp(
+	log *slog.Logger,
+	purger UrlPurger,
+	interval time.Duration,
+	archive bool,
+	trashRetention time.Duration,
+	batchSize int,
+) *App {
+	return &App{
+		log:            log,
+		purger:         purger,
+		interval:       interval,
+		archive:        archive,
+		trashRetention: trashRetention,
+		batchSize:      batchSize,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+}
+
+// MustStart runs the janitor until Stop is called
+func (app *App) MustStart() {
+	defer close(app.done)
+
+	app.log.Info("Janitor started", slog.Duration("interval", app.interval), slog.Bool("archive", app.archive))
+
+	ticker := time.NewTicker(app.interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-app.stop
+		cancel()
+	}()
+
+	for {
+		app.purge(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Stop interrupts the running purge and waits for the janitor to exit
+func (app *App) Stop() {
+	close(app.stop)
+	<-app.done
+}
+
+func (app *App) purge(ctx context.Context) {
+	app.purgeBatches(ctx, "expired", func(ctx context.Context) (int64, error) {
+		return app.purger.PurgeExpiredUrls(ctx, app.archive, app.batchSize)
+	})
+
+	before := time.Now().Add(-app.trashRetention)
+	app.purgeBatches(ctx, "deleted", func(ctx context.Context) (int64, error) {
+		return app.purger.PurgeDeletedUrls(ctx, before, app.batchSize)
+	})
+}
+
+// purgeBatches calls purge until it removes less than a full batch
+func (app *App) purgeBatches(ctx context.Context, kind string, purge func(ctx context.Context) (int64, error)) {
+	log := app.log.With(slog.String("op", opPurge), slog.String("kind", kind))
+
+	var total int64
+	for {
+		purged, err := purge(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error("failed to purge urls", sl.Err(err), sl.OpStack(err))
+			}
+			return
+		}
+
+		total += purged
+		if purged < int64(app.batchSize) {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Info("urls purged", slog.Int64("count", total))
+	}
+}
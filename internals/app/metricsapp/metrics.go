@@ -0,0 +1,90 @@
+package metricsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/storage/cache"
+)
+
+const (
+	opStart   = "metricsapp.MustStart"
+	opMetrics = "metricsapp.metrics"
+
+	timeout = 5 * time.Second
+)
+
+// CacheStats is implemented by the cache of alias lookups
+type CacheStats interface {
+	Stats() cache.Stats
+}
+
+// App serves counters of the server at GET /metrics. It listens apart from
+// the public redirect port, so the address should be reachable only by
+// monitoring
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	address    string
+}
+
+// metrics is the body of GET /metrics, counters of disabled parts are omitted
+type metrics struct {
+	UrlCache *cache.Stats `json:"url_cache,omitempty"`
+}
+
+// NewApp creates metrics server listening on address, e.g. 127.0.0.1:9090.
+// urlCache is nil when the cache is disabled
+func NewApp(log *slog.Logger, address string, urlCache CacheStats) *App {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		var body metrics
+		if urlCache != nil {
+			stats := urlCache.Stats()
+			body.UrlCache = &stats
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			log.Debug("failed to write metrics", slog.String("op", opMetrics), sl.Err(err))
+		}
+	})
+
+	return &App{
+		httpServer: &http.Server{
+			Handler:      mux,
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+		},
+		address: address,
+		log:     log,
+	}
+}
+
+func (app *App) MustStart() {
+	log := app.log.With(slog.String("op", opStart))
+
+	l, err := net.Listen("tcp", app.address)
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", opStart, err))
+	}
+
+	log.Info("metrics server started", slog.String("address", l.Addr().String()))
+
+	if err := app.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(fmt.Errorf("%s: %w", opStart, err))
+	}
+}
+
+func (app *App) Stop(ctx context.Context) {
+	if err := app.httpServer.Shutdown(ctx); err != nil {
+		app.log.Error("failed to stop metrics server", sl.Err(err))
+	}
+}
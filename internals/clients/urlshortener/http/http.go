@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
 )
 
 const (
@@ -16,6 +19,9 @@ const (
 	opShortenURL = "clients.ShortenURL"
 )
 
+// ErrUnexpectedStatus - сервис сокращения ответил статусом отличным от 200
+var ErrUnexpectedStatus = errors.New("unexpected status code")
+
 // LoggingInterceptor - интерсептор для логирования
 type LoggingInterceptor struct {
 	next http.RoundTripper
@@ -194,6 +200,7 @@ func NewClient(log *slog.Logger, timeout time.Duration, maxRetries int, baseUrl
 			Transport: transport,
 		},
 		shortenerBaseUrl: baseUrl,
+		log:              log,
 	}
 }
 
@@ -207,18 +214,18 @@ func (c *Client) ShortenURL(ctx context.Context, originalURL, alias string) (*Sh
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", opShortenURL, err)
+		return nil, sl.Wrap(opShortenURL, err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", c.shortenerBaseUrl+"", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", opShortenURL, err)
+		return nil, sl.Wrap(opShortenURL, err)
 	}
 	req.Header.Add("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", opShortenURL, err)
+		return nil, sl.Wrap(opShortenURL, err)
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -229,16 +236,16 @@ func (c *Client) ShortenURL(ctx context.Context, originalURL, alias string) (*Sh
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", opShortenURL, err)
+		return nil, sl.Wrap(opShortenURL, err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s: HTTP %d: %s", opShortenURL, resp.StatusCode, string(body))
+		return nil, sl.Wrap(opShortenURL, fmt.Errorf("%w: HTTP %d: %s", ErrUnexpectedStatus, resp.StatusCode, string(body)))
 	}
 
 	var shortenResponse ShortenResponse
 	if err := json.Unmarshal(body, &shortenResponse); err != nil {
-		return nil, fmt.Errorf("%s: %w", opShortenURL, err)
+		return nil, sl.Wrap(opShortenURL, err)
 	}
 
 	c.log.Debug("URL Shortened successfully",
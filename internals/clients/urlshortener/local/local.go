@@ -0,0 +1,56 @@
+package local
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	urlshortener "github.com/nhassl3/url-saver/internals/clients/urlshortener/http"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const opShortenURL = "clients.local.ShortenURL"
+
+type AliasGenerator interface {
+	Generate() (alias string, err error)
+}
+
+// Shortener shortens urls in-process, so the service runs without the
+// external shortener. It answers the same way the remote one does:
+// requested alias is kept, missing one is generated
+type Shortener struct {
+	log     *slog.Logger
+	aliases AliasGenerator
+}
+
+func NewShortener(log *slog.Logger, aliases AliasGenerator) *Shortener {
+	return &Shortener{
+		log:     log,
+		aliases: aliases,
+	}
+}
+
+func (s *Shortener) ShortenURL(ctx context.Context, originalURL, alias string) (*urlshortener.ShortenResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, sl.Wrap(opShortenURL, err)
+	}
+
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		generated, err := s.aliases.Generate()
+		if err != nil {
+			return nil, sl.Wrap(opShortenURL, err)
+		}
+		alias = generated
+	}
+
+	s.log.Debug("URL Shortened locally",
+		slog.String("alias", alias),
+		slog.String("url", originalURL),
+	)
+
+	return &urlshortener.ShortenResponse{
+		URL:   strings.TrimSpace(originalURL),
+		Alias: alias,
+	}, nil
+}
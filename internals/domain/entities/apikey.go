@@ -0,0 +1,14 @@
+package entities
+
+import "time"
+
+// ApiKey is a long-lived key of the user. The key itself is never stored,
+// only its hash and the prefix to recognize it
+type ApiKey struct {
+	ID        int64
+	UserID    int64
+	Name      string
+	Prefix    string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
@@ -1,7 +1,60 @@
 package entities
 
+import "time"
+
 type URL struct {
-	ID    int64
-	URL   string
-	Alias string
+	ID        int64
+	URL       string
+	Alias     string
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// ExpiresAt is the time after which the url is not found, zero time
+	// means the url never expires
+	ExpiresAt time.Time
+}
+
+// UrlMeta is optional information about the url. On update empty title,
+// nil tags and nil expiration keep the current values
+type UrlMeta struct {
+	Title string
+	Tags  []string
+	// ExpiresAt is the time after which the url is not found, zero time
+	// means the url never expires
+	ExpiresAt *time.Time
+}
+
+// Cursor points to the url after which the next page of a list starts.
+// It keeps every field a list can be sorted by
+type Cursor struct {
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Alias     string
+	ID        int64
+}
+
+type SortField string
+
+const (
+	SortByCreatedAt SortField = "created_at"
+	SortByUpdatedAt SortField = "updated_at"
+	SortByAlias     SortField = "alias"
+)
+
+// UrlFilter narrows and orders a list of urls. Zero values of the fields
+// are not applied
+type UrlFilter struct {
+	// Domain is the host of the url, e.g. "example.com"
+	Domain      string
+	AliasPrefix string
+	Tag         string
+	// Query is searched in the url and its title
+	Query       string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	UpdatedFrom time.Time
+	UpdatedTo   time.Time
+	// SortBy defaults to SortByCreatedAt
+	SortBy     SortField
+	Descending bool
 }
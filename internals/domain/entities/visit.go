@@ -0,0 +1,52 @@
+package entities
+
+import "time"
+
+// Visit is one redirect through an alias. The IP address of the visitor is
+// never stored, only its salted hash
+type Visit struct {
+	URLID     int64
+	Alias     string
+	VisitedAt time.Time
+	Referrer  string
+	UserAgent string
+	IPHash    string
+	// Country is not resolved yet and is always empty
+	Country string
+}
+
+// Bucket is the length of intervals visits are counted in
+type Bucket string
+
+const (
+	BucketHour Bucket = "hour"
+	BucketDay  Bucket = "day"
+	BucketWeek Bucket = "week"
+)
+
+// VisitStats counts visits and distinct visitors, visitors are told apart
+// by hashes of their IP addresses
+type VisitStats struct {
+	Visits   int64
+	Visitors int64
+}
+
+// VisitCount is the number of visits in the bucket starting at Start
+type VisitCount struct {
+	Start time.Time
+	VisitStats
+}
+
+// TopValue is a referrer or user agent with the number of its visits
+type TopValue struct {
+	Value  string
+	Visits int64
+}
+
+// UrlVisits is the url with the number of its visits
+type UrlVisits struct {
+	URLID  int64
+	URL    string
+	Alias  string
+	Visits int64
+}
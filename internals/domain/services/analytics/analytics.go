@@ -0,0 +1,319 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+	"github.com/nhassl3/url-saver/internals/storage"
+)
+
+const (
+	opTotals        = "services.analytics.Totals"
+	opSeries        = "services.analytics.Series"
+	opTopReferrers  = "services.analytics.TopReferrers"
+	opTopUserAgents = "services.analytics.TopUserAgents"
+	opTopLinks      = "services.analytics.TopLinks"
+
+	// maxBuckets limits the length of one series
+	maxBuckets = 1000
+	// defaultBuckets is the length of series requested without the start
+	defaultBuckets = 30
+
+	defaultTopLimit = 10
+	maxTopLimit     = 100
+)
+
+var (
+	ErrAliasNotFound   = errors.New("alias not found")
+	ErrInvalidBucket   = errors.New("bucket must be hour, day or week")
+	ErrInvalidRange    = errors.New("time range must start before it ends")
+	ErrTooManyBuckets  = errors.New("time range must span up to 1000 buckets")
+	ErrInvalidLimit    = errors.New("limit must be from 0 to 100")
+	ErrUnauthenticated = errors.New("user is not authenticated")
+)
+
+// Analytics reports visits of urls of the calling user. Visits are counted
+// from hourly rollups, so time ranges are widened to whole hours
+type Analytics struct {
+	log           *slog.Logger
+	urlProvider   ProviderUrl
+	visitProvider ProviderVisit
+}
+
+func NewAnalytics(log *slog.Logger, urlProvider ProviderUrl, visitProvider ProviderVisit) *Analytics {
+	return &Analytics{
+		log:           log,
+		urlProvider:   urlProvider,
+		visitProvider: visitProvider,
+	}
+}
+
+type ProviderUrl interface {
+	Url(ctx context.Context, userID int64, alias string) (url entities.URL, err error)
+}
+
+type ProviderVisit interface {
+	VisitStats(ctx context.Context, urlID int64, from, to time.Time) (stats entities.VisitStats, err error)
+	VisitSeries(ctx context.Context, urlID int64, bucket entities.Bucket, from, to time.Time) (series []entities.VisitCount, err error)
+	TopReferrers(ctx context.Context, urlID int64, from, to time.Time, limit int) (top []entities.TopValue, err error)
+	TopUserAgents(ctx context.Context, urlID int64, from, to time.Time, limit int) (top []entities.TopValue, err error)
+	TopUrls(ctx context.Context, userID int64, from, to time.Time, limit int) (top []entities.UrlVisits, err error)
+}
+
+// Totals counts visits and unique visitors of the alias made in [from, to).
+// Zero from counts from the beginning, zero to counts until now
+func (a *Analytics) Totals(ctx context.Context, alias string, from, to time.Time) (stats entities.VisitStats, err error) {
+	log := a.log.With(slog.String("op", opTotals))
+
+	from, to, err = hourRange(from, to)
+	if err != nil {
+		return entities.VisitStats{}, sl.Wrap(opTotals, err)
+	}
+
+	urlID, err := a.urlID(ctx, log, alias)
+	if err != nil {
+		return entities.VisitStats{}, sl.Wrap(opTotals, err)
+	}
+
+	stats, err = a.visitProvider.VisitStats(ctx, urlID, from, to)
+	if err != nil {
+		log.Error("failed to count visits", sl.Err(err), sl.OpStack(err))
+
+		return entities.VisitStats{}, sl.Wrap(opTotals, err)
+	}
+
+	return
+}
+
+// Series counts visits and unique visitors of the alias made in [from, to)
+// per bucket. Every bucket of the range is returned, including empty ones.
+// Zero to means now, zero from means 30 buckets before to
+func (a *Analytics) Series(
+	ctx context.Context,
+	alias string,
+	bucket entities.Bucket,
+	from, to time.Time,
+) (series []entities.VisitCount, err error) {
+	log := a.log.With(slog.String("op", opSeries))
+
+	if _, ok := bucketLengths[bucket]; !ok {
+		return nil, sl.Wrap(opSeries, ErrInvalidBucket)
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = nextBucket(bucketOf(to, bucket), bucket, -defaultBuckets)
+	}
+	if !from.Before(to) {
+		return nil, sl.Wrap(opSeries, ErrInvalidRange)
+	}
+
+	first := bucketOf(from, bucket)
+	if to.Sub(first) > time.Duration(maxBuckets)*bucketLengths[bucket] {
+		return nil, sl.Wrap(opSeries, ErrTooManyBuckets)
+	}
+
+	urlID, err := a.urlID(ctx, log, alias)
+	if err != nil {
+		return nil, sl.Wrap(opSeries, err)
+	}
+
+	counts, err := a.visitProvider.VisitSeries(ctx, urlID, bucket, first, to)
+	if err != nil {
+		log.Error("failed to count visits", sl.Err(err), sl.OpStack(err))
+
+		return nil, sl.Wrap(opSeries, err)
+	}
+
+	return fillBuckets(counts, bucket, first, to), nil
+}
+
+// TopReferrers returns up to limit referrers of the alias with most visits
+// made in [from, to), zero limit means 10. Empty referrer stands for
+// direct visits
+func (a *Analytics) TopReferrers(
+	ctx context.Context,
+	alias string,
+	from, to time.Time,
+	limit int,
+) (top []entities.TopValue, err error) {
+	return a.topValues(ctx, opTopReferrers, alias, from, to, limit, a.visitProvider.TopReferrers)
+}
+
+// TopUserAgents returns up to limit user agents of the alias with most
+// visits made in [from, to), zero limit means 10
+func (a *Analytics) TopUserAgents(
+	ctx context.Context,
+	alias string,
+	from, to time.Time,
+	limit int,
+) (top []entities.TopValue, err error) {
+	return a.topValues(ctx, opTopUserAgents, alias, from, to, limit, a.visitProvider.TopUserAgents)
+}
+
+func (a *Analytics) topValues(
+	ctx context.Context,
+	op, alias string,
+	from, to time.Time,
+	limit int,
+	top func(ctx context.Context, urlID int64, from, to time.Time, limit int) ([]entities.TopValue, error),
+) ([]entities.TopValue, error) {
+	log := a.log.With(slog.String("op", op))
+
+	limit, err := topLimit(limit)
+	if err != nil {
+		return nil, sl.Wrap(op, err)
+	}
+
+	from, to, err = hourRange(from, to)
+	if err != nil {
+		return nil, sl.Wrap(op, err)
+	}
+
+	urlID, err := a.urlID(ctx, log, alias)
+	if err != nil {
+		return nil, sl.Wrap(op, err)
+	}
+
+	values, err := top(ctx, urlID, from, to, limit)
+	if err != nil {
+		log.Error("failed to count visits", sl.Err(err), sl.OpStack(err))
+
+		return nil, sl.Wrap(op, err)
+	}
+
+	return values, nil
+}
+
+// TopLinks returns up to limit urls of the calling user with most visits
+// made in [from, to), zero limit means 10. Removed and expired urls are
+// not reported
+func (a *Analytics) TopLinks(ctx context.Context, from, to time.Time, limit int) (top []entities.UrlVisits, err error) {
+	log := a.log.With(slog.String("op", opTopLinks))
+
+	userID, ok := userctx.UserID(ctx)
+	if !ok {
+		return nil, sl.Wrap(opTopLinks, ErrUnauthenticated)
+	}
+
+	if limit, err = topLimit(limit); err != nil {
+		return nil, sl.Wrap(opTopLinks, err)
+	}
+
+	from, to, err = hourRange(from, to)
+	if err != nil {
+		return nil, sl.Wrap(opTopLinks, err)
+	}
+
+	top, err = a.visitProvider.TopUrls(ctx, userID, from, to, limit)
+	if err != nil {
+		log.Error("failed to count visits", sl.Err(err), sl.OpStack(err))
+
+		return nil, sl.Wrap(opTopLinks, err)
+	}
+
+	return
+}
+
+// urlID returns ID of the url of the calling user with the alias
+func (a *Analytics) urlID(ctx context.Context, log *slog.Logger, alias string) (int64, error) {
+	userID, ok := userctx.UserID(ctx)
+	if !ok {
+		return 0, ErrUnauthenticated
+	}
+
+	url, err := a.urlProvider.Url(ctx, userID, alias)
+	if err != nil {
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			return 0, ErrAliasNotFound
+		}
+		log.Error("failed to get url", sl.Err(err), sl.OpStack(err))
+
+		return 0, err
+	}
+
+	return url.ID, nil
+}
+
+// hourRange fills zero ends of the range and widens it to whole hours
+func hourRange(from, to time.Time) (time.Time, time.Time, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = time.Unix(0, 0)
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, ErrInvalidRange
+	}
+
+	return bucketOf(from, entities.BucketHour), to, nil
+}
+
+func topLimit(limit int) (int, error) {
+	switch {
+	case limit < 0 || limit > maxTopLimit:
+		return 0, ErrInvalidLimit
+	case limit == 0:
+		return defaultTopLimit, nil
+	default:
+		return limit, nil
+	}
+}
+
+// bucketLengths are the longest lengths of buckets, they are used only to
+// limit the number of buckets in the range
+var bucketLengths = map[entities.Bucket]time.Duration{
+	entities.BucketHour: time.Hour,
+	entities.BucketDay:  24 * time.Hour,
+	entities.BucketWeek: 7 * 24 * time.Hour,
+}
+
+// bucketOf returns the start of the bucket containing t, buckets are
+// aligned in UTC and weeks start on Monday
+func bucketOf(t time.Time, bucket entities.Bucket) time.Time {
+	t = t.UTC()
+	switch bucket {
+	case entities.BucketHour:
+		return t.Truncate(time.Hour)
+	case entities.BucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// nextBucket moves the bucket start n buckets forward
+func nextBucket(start time.Time, bucket entities.Bucket, n int) time.Time {
+	switch bucket {
+	case entities.BucketHour:
+		return start.Add(time.Duration(n) * time.Hour)
+	case entities.BucketWeek:
+		return start.AddDate(0, 0, 7*n)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
+
+// fillBuckets adds empty buckets missing in counts between first and to
+func fillBuckets(counts []entities.VisitCount, bucket entities.Bucket, first, to time.Time) []entities.VisitCount {
+	stats := make(map[time.Time]entities.VisitStats, len(counts))
+	for _, count := range counts {
+		stats[count.Start.UTC()] = count.VisitStats
+	}
+
+	var series []entities.VisitCount
+	for start := first; start.Before(to); start = nextBucket(start, bucket, 1) {
+		series = append(series, entities.VisitCount{Start: start, VisitStats: stats[start]})
+	}
+
+	return series
+}
@@ -0,0 +1,239 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+	"github.com/nhassl3/url-saver/internals/storage/memory"
+)
+
+const userID = 1
+
+func date(day, hour, minute int) time.Time {
+	// 2026-10-12 is a Monday
+	return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestBucketOf(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		bucket entities.Bucket
+		want   time.Time
+	}{
+		{name: "hour", t: date(14, 10, 35), bucket: entities.BucketHour, want: date(14, 10, 0)},
+		{name: "hour start", t: date(14, 10, 0), bucket: entities.BucketHour, want: date(14, 10, 0)},
+		{name: "day", t: date(14, 23, 59), bucket: entities.BucketDay, want: date(14, 0, 0)},
+		{name: "day start", t: date(14, 0, 0), bucket: entities.BucketDay, want: date(14, 0, 0)},
+		{name: "week on monday", t: date(12, 0, 0), bucket: entities.BucketWeek, want: date(12, 0, 0)},
+		{name: "week on wednesday", t: date(14, 12, 0), bucket: entities.BucketWeek, want: date(12, 0, 0)},
+		{name: "week on sunday", t: date(18, 23, 59), bucket: entities.BucketWeek, want: date(12, 0, 0)},
+		{name: "week on next monday", t: date(19, 0, 0), bucket: entities.BucketWeek, want: date(19, 0, 0)},
+		{
+			name: "week across months", t: time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC),
+			bucket: entities.BucketWeek, want: date(26, 0, 0),
+		},
+		{
+			name: "week across years", t: time.Date(2027, 1, 2, 12, 0, 0, 0, time.UTC),
+			bucket: entities.BucketWeek, want: time.Date(2026, 12, 28, 0, 0, 0, 0, time.UTC),
+		},
+		// Monday 01:00 in Moscow is still Sunday in UTC
+		{
+			name: "week in other zone", t: time.Date(2026, 10, 19, 1, 0, 0, 0, moscow),
+			bucket: entities.BucketWeek, want: date(12, 0, 0),
+		},
+		{
+			name: "day in other zone", t: time.Date(2026, 10, 15, 2, 0, 0, 0, moscow),
+			bucket: entities.BucketDay, want: date(14, 0, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bucketOf(tt.t, tt.bucket)
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("bucketOf(%v, %s) = %v, want %v", tt.t, tt.bucket, got, tt.want)
+			}
+			if tt.bucket == entities.BucketWeek && got.Weekday() != time.Monday {
+				t.Errorf("week starts on %s", got.Weekday())
+			}
+		})
+	}
+}
+
+func TestFillBuckets(t *testing.T) {
+	stats := func(visits int64) entities.VisitStats {
+		return entities.VisitStats{Visits: visits, Visitors: 1}
+	}
+
+	tests := []struct {
+		name   string
+		counts []entities.VisitCount
+		bucket entities.Bucket
+		first  time.Time
+		to     time.Time
+		want   []entities.VisitCount
+	}{
+		{
+			name: "no visits", bucket: entities.BucketHour, first: date(14, 10, 0), to: date(14, 13, 0),
+			want: []entities.VisitCount{{Start: date(14, 10, 0)}, {Start: date(14, 11, 0)}, {Start: date(14, 12, 0)}},
+		},
+		{
+			name: "gaps", bucket: entities.BucketDay, first: date(12, 0, 0), to: date(16, 0, 0),
+			counts: []entities.VisitCount{
+				{Start: date(12, 0, 0), VisitStats: stats(2)},
+				{Start: date(15, 0, 0), VisitStats: stats(5)},
+			},
+			want: []entities.VisitCount{
+				{Start: date(12, 0, 0), VisitStats: stats(2)},
+				{Start: date(13, 0, 0)},
+				{Start: date(14, 0, 0)},
+				{Start: date(15, 0, 0), VisitStats: stats(5)},
+			},
+		},
+		{
+			name: "partial last bucket", bucket: entities.BucketDay, first: date(12, 0, 0), to: date(13, 0, 1),
+			want: []entities.VisitCount{{Start: date(12, 0, 0)}, {Start: date(13, 0, 0)}},
+		},
+		{
+			name: "weeks", bucket: entities.BucketWeek, first: date(12, 0, 0), to: time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC),
+			counts: []entities.VisitCount{{Start: date(26, 0, 0), VisitStats: stats(3)}},
+			want: []entities.VisitCount{
+				{Start: date(12, 0, 0)},
+				{Start: date(19, 0, 0)},
+				{Start: date(26, 0, 0), VisitStats: stats(3)},
+				{Start: time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "counts in other zone", bucket: entities.BucketHour, first: date(14, 10, 0), to: date(14, 11, 0),
+			counts: []entities.VisitCount{
+				{Start: time.Date(2026, 10, 14, 13, 0, 0, 0, time.FixedZone("MSK", 3*60*60)), VisitStats: stats(4)},
+			},
+			want: []entities.VisitCount{{Start: date(14, 10, 0), VisitStats: stats(4)}},
+		},
+		{name: "empty range", bucket: entities.BucketHour, first: date(14, 10, 0), to: date(14, 10, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fillBuckets(tt.counts, tt.bucket, tt.first, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("fillBuckets() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tt.want[i].Start) || got[i].VisitStats != tt.want[i].VisitStats {
+					t.Errorf("bucket %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSeries(t *testing.T) {
+	ctx := userctx.WithUserID(context.Background(), userID)
+
+	s := memory.NewStorage(true)
+	urlID, err := s.SaveUrl(ctx, userID, "https://example.com", "alias", entities.UrlMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SaveVisits(ctx, []entities.Visit{
+		{URLID: urlID, Alias: "alias", VisitedAt: date(12, 9, 0), IPHash: "a"},
+		{URLID: urlID, Alias: "alias", VisitedAt: date(12, 18, 0), IPHash: "a"},
+		{URLID: urlID, Alias: "alias", VisitedAt: date(14, 9, 0), IPHash: "b"},
+		{URLID: urlID, Alias: "alias", VisitedAt: date(20, 9, 0), IPHash: "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAnalytics(slog.New(slog.DiscardHandler), s, s)
+
+	tests := []struct {
+		name    string
+		bucket  entities.Bucket
+		from    time.Time
+		to      time.Time
+		want    []int64
+		wantErr error
+	}{
+		{name: "days with empty ones", bucket: entities.BucketDay, from: date(12, 0, 0), to: date(16, 0, 0), want: []int64{2, 0, 1, 0}},
+		{name: "weeks from wednesday", bucket: entities.BucketWeek, from: date(14, 0, 0), to: date(26, 0, 0), want: []int64{3, 1}},
+		{name: "hours", bucket: entities.BucketHour, from: date(12, 8, 30), to: date(12, 10, 0), want: []int64{0, 1}},
+		{name: "before any visit", bucket: entities.BucketDay, from: date(1, 0, 0), to: date(3, 0, 0), want: []int64{0, 0}},
+		{name: "most buckets", bucket: entities.BucketHour, from: date(1, 0, 0), to: date(1, 0, 0).Add(1000 * time.Hour)},
+		{
+			name: "too many hours", bucket: entities.BucketHour, from: date(1, 0, 0), to: date(1, 0, 0).Add(1001 * time.Hour),
+			wantErr: ErrTooManyBuckets,
+		},
+		{
+			name: "too many hours from the middle of one", bucket: entities.BucketHour,
+			from: date(1, 0, 30), to: date(1, 0, 0).Add(1000*time.Hour + time.Minute), wantErr: ErrTooManyBuckets,
+		},
+		{
+			name: "too many days", bucket: entities.BucketDay, from: date(1, 0, 0), to: date(1, 0, 0).AddDate(0, 0, 1001),
+			wantErr: ErrTooManyBuckets,
+		},
+		{
+			name: "too many weeks", bucket: entities.BucketWeek, from: date(12, 0, 0), to: date(12, 0, 0).AddDate(0, 0, 7*1001),
+			wantErr: ErrTooManyBuckets,
+		},
+		{name: "empty range", bucket: entities.BucketDay, from: date(14, 0, 0), to: date(14, 0, 0), wantErr: ErrInvalidRange},
+		{name: "reversed range", bucket: entities.BucketDay, from: date(15, 0, 0), to: date(14, 0, 0), wantErr: ErrInvalidRange},
+		{name: "unknown bucket", bucket: "month", from: date(1, 0, 0), to: date(14, 0, 0), wantErr: ErrInvalidBucket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series, err := a.Series(ctx, "alias", tt.bucket, tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Series() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil || tt.want == nil {
+				return
+			}
+
+			if len(series) != len(tt.want) {
+				t.Fatalf("Series() returned %d buckets, want %d", len(series), len(tt.want))
+			}
+			first := bucketOf(tt.from, tt.bucket)
+			for i, count := range series {
+				if want := nextBucket(first, tt.bucket, i); !count.Start.Equal(want) {
+					t.Errorf("bucket %d starts at %v, want %v", i, count.Start, want)
+				}
+				if count.Visits != tt.want[i] {
+					t.Errorf("bucket %d has %d visits, want %d", i, count.Visits, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSeriesDefaultRange(t *testing.T) {
+	ctx := userctx.WithUserID(context.Background(), userID)
+
+	s := memory.NewStorage(true)
+	if _, err := s.SaveUrl(ctx, userID, "https://example.com", "alias", entities.UrlMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	a := NewAnalytics(slog.New(slog.DiscardHandler), s, s)
+
+	for _, bucket := range []entities.Bucket{entities.BucketHour, entities.BucketDay, entities.BucketWeek} {
+		t.Run(string(bucket), func(t *testing.T) {
+			series, err := a.Series(ctx, "alias", bucket, time.Time{}, time.Time{})
+			if err != nil {
+				t.Fatalf("Series() error = %v", err)
+			}
+
+			// 30 whole buckets and the current one
+			if len(series) != defaultBuckets+1 {
+				t.Errorf("Series() returned %d buckets, want %d", len(series), defaultBuckets+1)
+			}
+		})
+	}
+}
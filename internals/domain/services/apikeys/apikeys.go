@@ -0,0 +1,191 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+	"github.com/nhassl3/url-saver/internals/storage"
+)
+
+const (
+	opCreate       = "services.apikeys.Create"
+	opList         = "services.apikeys.List"
+	opRevoke       = "services.apikeys.Revoke"
+	opAuthenticate = "services.apikeys.Authenticate"
+
+	// keyPrefix marks keys of this service, so leaked keys are easy to find
+	keyPrefix    = "us_"
+	keyBytes     = 32
+	prefixLength = len(keyPrefix) + 8
+	maxNameLen   = 100
+)
+
+var (
+	ErrInvalidApiKey   = errors.New("invalid api key")
+	ErrApiKeyNotFound  = errors.New("api key not found")
+	ErrInvalidName     = errors.New("api key name must be from 1 to 100 characters")
+	ErrUnauthenticated = errors.New("user is not authenticated")
+	ErrApiKeyForbidden = errors.New("api keys are managed with a bearer token, not with an api key")
+)
+
+type ApiKeys struct {
+	log         *slog.Logger
+	keySaver    SaverApiKey
+	keyProvider ProviderApiKey
+	keyRevoker  RevokerApiKey
+}
+
+func NewApiKeys(
+	log *slog.Logger,
+	keySaver SaverApiKey,
+	keyProvider ProviderApiKey,
+	keyRevoker RevokerApiKey,
+) *ApiKeys {
+	return &ApiKeys{
+		log:         log,
+		keySaver:    keySaver,
+		keyProvider: keyProvider,
+		keyRevoker:  keyRevoker,
+	}
+}
+
+type SaverApiKey interface {
+	SaveApiKey(ctx context.Context, userID int64, name, prefix, keyHash string) (keyID int64, err error)
+}
+
+type ProviderApiKey interface {
+	ApiKey(ctx context.Context, keyHash string) (key entities.ApiKey, err error)
+	ApiKeys(ctx context.Context, userID int64) (keys []entities.ApiKey, err error)
+}
+
+type RevokerApiKey interface {
+	RevokeApiKey(ctx context.Context, userID, keyID int64) (err error)
+}
+
+// Create issues new key for the calling user. The key is returned only
+// once, the storage keeps just its hash
+func (a *ApiKeys) Create(ctx context.Context, name string) (key string, keyID int64, err error) {
+	log := a.log.With(slog.String("op", opCreate))
+
+	userID, err := managerID(ctx)
+	if err != nil {
+		return "", 0, sl.Wrap(opCreate, err)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLen {
+		return "", 0, sl.Wrap(opCreate, ErrInvalidName)
+	}
+
+	raw := make([]byte, keyBytes)
+	if _, err = rand.Read(raw); err != nil {
+		return "", 0, sl.Wrap(opCreate, err)
+	}
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	keyID, err = a.keySaver.SaveApiKey(ctx, userID, name, key[:prefixLength], Hash(key))
+	if err != nil {
+		log.Error("failed to save api key", sl.Err(err), sl.OpStack(err))
+
+		return "", 0, sl.Wrap(opCreate, err)
+	}
+
+	return key, keyID, nil
+}
+
+// List returns all keys of the calling user including revoked ones
+func (a *ApiKeys) List(ctx context.Context) (keys []entities.ApiKey, err error) {
+	log := a.log.With(slog.String("op", opList))
+
+	userID, err := managerID(ctx)
+	if err != nil {
+		return nil, sl.Wrap(opList, err)
+	}
+
+	keys, err = a.keyProvider.ApiKeys(ctx, userID)
+	if err != nil {
+		log.Error("failed to list api keys", sl.Err(err), sl.OpStack(err))
+
+		return nil, sl.Wrap(opList, err)
+	}
+
+	return
+}
+
+// Revoke revokes the key of the calling user, revoked keys stay listed
+func (a *ApiKeys) Revoke(ctx context.Context, keyID int64) (err error) {
+	log := a.log.With(slog.String("op", opRevoke))
+
+	userID, err := managerID(ctx)
+	if err != nil {
+		return sl.Wrap(opRevoke, err)
+	}
+
+	if err = a.keyRevoker.RevokeApiKey(ctx, userID, keyID); err != nil {
+		if errors.Is(err, storage.ErrApiKeyNotFound) {
+			return sl.Wrap(opRevoke, ErrApiKeyNotFound)
+		}
+		log.Error("failed to revoke api key", sl.Err(err), sl.OpStack(err))
+
+		return sl.Wrap(opRevoke, err)
+	}
+
+	return nil
+}
+
+// managerID returns ID of the user managing own keys. Requests authenticated
+// by an api key can't manage keys, so a leaked key can't issue new ones or
+// revoke the others
+func managerID(ctx context.Context) (int64, error) {
+	userID, ok := userctx.UserID(ctx)
+	if !ok {
+		return 0, ErrUnauthenticated
+	}
+	if userctx.ByApiKey(ctx) {
+		return 0, ErrApiKeyForbidden
+	}
+
+	return userID, nil
+}
+
+// Authenticate returns ID of the user owning the key. Unknown and revoked
+// keys are reported as ErrInvalidApiKey
+func (a *ApiKeys) Authenticate(ctx context.Context, key string) (userID int64, err error) {
+	log := a.log.With(slog.String("op", opAuthenticate))
+
+	if !strings.HasPrefix(key, keyPrefix) {
+		return 0, sl.Wrap(opAuthenticate, ErrInvalidApiKey)
+	}
+
+	apiKey, err := a.keyProvider.ApiKey(ctx, Hash(key))
+	if err != nil {
+		if errors.Is(err, storage.ErrApiKeyNotFound) {
+			return 0, sl.Wrap(opAuthenticate, ErrInvalidApiKey)
+		}
+		log.Error("failed to get api key", sl.Err(err), sl.OpStack(err))
+
+		return 0, sl.Wrap(opAuthenticate, err)
+	}
+
+	if apiKey.RevokedAt != nil {
+		return 0, sl.Wrap(opAuthenticate, ErrInvalidApiKey)
+	}
+
+	return apiKey.UserID, nil
+}
+
+// Hash returns hex encoded SHA-256 of the key. Keys are random and long
+// enough, so a slow password hash is not needed
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,135 @@
+package apikeys_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/nhassl3/url-saver/internals/domain/services/apikeys"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+	"github.com/nhassl3/url-saver/internals/storage/memory"
+)
+
+const (
+	userID      = 1
+	otherUserID = 2
+)
+
+func newApiKeys() *apikeys.ApiKeys {
+	s := memory.NewStorage(true)
+
+	return apikeys.NewApiKeys(slog.New(slog.DiscardHandler), s, s, s)
+}
+
+func userContext(userID int64) context.Context {
+	return userctx.WithUserID(context.Background(), userID)
+}
+
+func TestCreateAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	keys := newApiKeys()
+
+	key, keyID, err := keys.Create(userContext(userID), " ci ")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if got, err := keys.Authenticate(ctx, key); err != nil || got != userID {
+		t.Errorf("Authenticate() = %d, %v, want %d", got, err, userID)
+	}
+
+	list, err := keys.List(userContext(userID))
+	if err != nil || len(list) != 1 || list[0].Name != "ci" || !strings.HasPrefix(key, list[0].Prefix) {
+		t.Errorf("List() = %+v, %v, want the created key", list, err)
+	}
+
+	if err = keys.Revoke(userContext(otherUserID), keyID); !errors.Is(err, apikeys.ErrApiKeyNotFound) {
+		t.Errorf("Revoke() by other user error = %v, want %v", err, apikeys.ErrApiKeyNotFound)
+	}
+	if err = keys.Revoke(userContext(userID), keyID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+
+	if _, err = keys.Authenticate(ctx, key); !errors.Is(err, apikeys.ErrInvalidApiKey) {
+		t.Errorf("Authenticate() with revoked key error = %v, want %v", err, apikeys.ErrInvalidApiKey)
+	}
+}
+
+func TestManageRejects(t *testing.T) {
+	byApiKey := userctx.WithApiKey(userContext(userID))
+
+	tests := []struct {
+		name    string
+		call    func(keys *apikeys.ApiKeys) error
+		wantErr error
+	}{
+		{
+			name: "create with api key",
+			call: func(keys *apikeys.ApiKeys) error {
+				_, _, err := keys.Create(byApiKey, "minted")
+				return err
+			},
+			wantErr: apikeys.ErrApiKeyForbidden,
+		},
+		{
+			name: "list with api key",
+			call: func(keys *apikeys.ApiKeys) error {
+				_, err := keys.List(byApiKey)
+				return err
+			},
+			wantErr: apikeys.ErrApiKeyForbidden,
+		},
+		{
+			name:    "revoke with api key",
+			call:    func(keys *apikeys.ApiKeys) error { return keys.Revoke(byApiKey, 1) },
+			wantErr: apikeys.ErrApiKeyForbidden,
+		},
+		{
+			name: "unauthenticated",
+			call: func(keys *apikeys.ApiKeys) error {
+				_, _, err := keys.Create(context.Background(), "anonymous")
+				return err
+			},
+			wantErr: apikeys.ErrUnauthenticated,
+		},
+		{
+			name: "empty name",
+			call: func(keys *apikeys.ApiKeys) error {
+				_, _, err := keys.Create(userContext(userID), "  ")
+				return err
+			},
+			wantErr: apikeys.ErrInvalidName,
+		},
+		{
+			name: "long name",
+			call: func(keys *apikeys.ApiKeys) error {
+				_, _, err := keys.Create(userContext(userID), strings.Repeat("a", 101))
+				return err
+			},
+			wantErr: apikeys.ErrInvalidName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(newApiKeys()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRejects(t *testing.T) {
+	keys := newApiKeys()
+	key, _, err := keys.Create(userContext(userID), "ci")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	for _, bad := range []string{"", "us_", key[:len(key)-1], "xx" + key[2:], key + "a"} {
+		if _, err := keys.Authenticate(context.Background(), bad); !errors.Is(err, apikeys.ErrInvalidApiKey) {
+			t.Errorf("Authenticate(%q) error = %v, want %v", bad, err, apikeys.ErrInvalidApiKey)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package urlnorm
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/nhassl3/url-saver/internals/storage"
+	"golang.org/x/net/idna"
+)
+
+var (
+	defaultPorts = map[string]string{
+		"http":  "80",
+		"https": "443",
+	}
+
+	// trackingParams are removed from the query, utm_* are matched by prefix
+	trackingParams = map[string]struct{}{
+		"fbclid":  {},
+		"gclid":   {},
+		"yclid":   {},
+		"msclkid": {},
+	}
+
+	// privateZones are names which never resolve to a public host
+	privateZones = []string{"localhost", "local", "internal", "lan", "home.arpa"}
+)
+
+// Normalizer validates urls and brings them to the canonical form, so the
+// same target is stored the same way whatever the client sent
+type Normalizer struct {
+	schemes      map[string]struct{}
+	maxLength    int
+	allowPrivate bool
+}
+
+// NewNormalizer creates normalizer accepting urls with the given schemes and
+// at most maxLength bytes long. Unless allowPrivate is set, urls pointing to
+// loopback, private and link-local addresses are rejected. Host names are
+// not resolved, so only IP literals and well-known local zones are caught
+func NewNormalizer(schemes []string, maxLength int, allowPrivate bool) *Normalizer {
+	allowed := make(map[string]struct{}, len(schemes))
+	for _, scheme := range schemes {
+		allowed[strings.ToLower(scheme)] = struct{}{}
+	}
+
+	return &Normalizer{
+		schemes:      allowed,
+		maxLength:    maxLength,
+		allowPrivate: allowPrivate,
+	}
+}
+
+// Normalize returns canonical form of the url: lowercase scheme and punycode
+// host, no default port, no trailing slash, no tracking parameters and
+// sorted query. Invalid url is reported as storage.ErrUrlIsInvalid with reason
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if len(rawURL) > n.maxLength {
+		return "", invalid("longer than %d bytes", n.maxLength)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", invalid("can't be parsed")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if _, ok := n.schemes[u.Scheme]; !ok {
+		return "", invalid("scheme %q is not allowed", u.Scheme)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", invalid("no host")
+	}
+	if u.User != nil {
+		return "", invalid("credentials are not allowed")
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	if !n.allowPrivate && isPrivateHost(host) {
+		return "", invalid("points to a private network")
+	}
+
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			if _, ok := trackingParams[strings.ToLower(key)]; ok || strings.HasPrefix(strings.ToLower(key), "utm_") {
+				query.Del(key)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	u.ForceQuery = false
+
+	normalized := u.String()
+	if len(normalized) > n.maxLength {
+		return "", invalid("longer than %d bytes", n.maxLength)
+	}
+
+	return normalized, nil
+}
+
+// normalizeHost lowercases the host and converts international names to punycode
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", invalid("no host")
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", invalid("host %q is not a valid domain name", host)
+	}
+
+	return strings.ToLower(ascii), nil
+}
+
+func isPrivateHost(host string) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+			addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsMulticast()
+	}
+
+	for _, zone := range privateZones {
+		if host == zone || strings.HasSuffix(host, "."+zone) {
+			return true
+		}
+	}
+	// single label names are resolved inside the local network
+	return !strings.Contains(host, ".")
+}
+
+func invalid(reason string, args ...any) error {
+	return fmt.Errorf("%w: %s", storage.ErrUrlIsInvalid, fmt.Sprintf(reason, args...))
+}
@@ -0,0 +1,69 @@
+package urlsaver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nhassl3/url-saver/internals/lib/aliasgen"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const maxAliasLen = 50
+
+// shorten is the pipeline every alias of a url goes through before it is
+// stored, whether it came from the client or from the generator, on Save
+// and on Update: the shortener call followed by checkAlias. Only the alias
+// is taken from the shortener, the url is stored in the canonical form
+// made by the normalizer
+func (u *UrlSaver) shorten(ctx context.Context, log *slog.Logger, url, alias string) (shortAlias string, err error) {
+	res, err := u.shortener.ShortenURL(ctx, url, alias)
+	if err != nil {
+		log.Error("failed to shorten url", sl.Err(err), sl.OpStack(err))
+
+		return "", fmt.Errorf("%w: %w", ErrShortener, err)
+	}
+
+	return u.checkAlias(res.GetAlias())
+}
+
+// checkAlias normalizes the alias and checks it may be stored
+func (u *UrlSaver) checkAlias(alias string) (string, error) {
+	alias = normalizeAlias(alias)
+	if !validAlias(alias) {
+		return "", ErrAliasInvalid
+	}
+	if u.isReserved(alias) {
+		return "", ErrAliasReserved
+	}
+
+	return alias, nil
+}
+
+// normalizeAlias drops surrounding spaces and slashes, which can't be
+// a part of alias in the redirect path
+func normalizeAlias(alias string) string {
+	return strings.Trim(alias, " \t\r\n/")
+}
+
+// validAlias reports whether alias is safe to be a single path segment
+func validAlias(alias string) bool {
+	if alias == "" || utf8.RuneCountInString(alias) > maxAliasLen || strings.Trim(alias, ".") == "" {
+		return false
+	}
+
+	for _, r := range alias {
+		if !aliasgen.IsAliasRune(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (u *UrlSaver) isReserved(alias string) bool {
+	_, ok := u.reservedAliases[strings.ToLower(alias)]
+	return ok
+}
@@ -4,106 +4,588 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 
 	urlsv1 "github.com/nhassl3/url-saver-contracts/generated/go/urlsaver"
+	urlshortener "github.com/nhassl3/url-saver/internals/clients/urlshortener/http"
 	"github.com/nhassl3/url-saver/internals/domain/entities"
 	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
 	"github.com/nhassl3/url-saver/internals/storage"
 )
 
 const (
-	opSave          = "services.urlsaver.Save"
-	opGet           = "services.urlsaver.Get"
-	opUpdateByID    = "services.urlsaver.UpdateByID"
-	onUpdateByAlias = "services.urlsaver.UpdateByAlias"
-	opRemoveByID    = "services.urlsaver.RemoveByID"
-	opRemoveByAlias = "services.urlsaver.RemoveByAlias"
-	opList          = "services.urlsaver.List"
+	opSave           = "services.urlsaver.Save"
+	opGet            = "services.urlsaver.Get"
+	opUpdateByID     = "services.urlsaver.UpdateByID"
+	opUpdateByAlias  = "services.urlsaver.UpdateByAlias"
+	opRemoveByID     = "services.urlsaver.RemoveByID"
+	opRemoveByAlias  = "services.urlsaver.RemoveByAlias"
+	opRestoreByID    = "services.urlsaver.RestoreByID"
+	opRestoreByAlias = "services.urlsaver.RestoreByAlias"
+	opList           = "services.urlsaver.List"
 )
 
 var (
-	ErrAliasExists = errors.New("alias already exists")
+	ErrAliasExists       = errors.New("alias already exists")
+	ErrAliasNotFound     = errors.New("alias not found")
+	ErrAliasReserved     = errors.New("alias is reserved")
+	ErrAliasInvalid      = errors.New("alias must be up to 50 letters, digits or any of '-', '_', '.', '~'")
+	ErrShortener         = errors.New("url shortener failed")
+	ErrInvalidExpiration = errors.New("expiration time must be in the future")
+	ErrAliasGeneration   = errors.New("failed to generate free alias")
+	ErrInvalidPageToken  = errors.New("invalid page token")
+	ErrUnauthenticated   = errors.New("user is not authenticated")
 )
 
 type UrlSaver struct {
-	log         *slog.Logger
-	urlSaver    SaverUrl
-	urlProvider ProviderUrl
-	urlUpdater  UpdaterUrl
+	log             *slog.Logger
+	urlSaver        SaverUrl
+	urlProvider     ProviderUrl
+	urlUpdater      UpdaterUrl
+	shortener       Shortener
+	urlNormalizer   UrlNormalizer
+	pageTokens      PageTokenCodec
+	maxPageSize     int
+	aliases         AliasGenerator
+	aliasRetries    int
+	reservedAliases map[string]struct{}
+	dedupe          bool
 }
 
+// Options are the tunables of the service
+type Options struct {
+	// MaxPageSize limits the number of urls on a page of List
+	MaxPageSize int
+	// AliasRetries is how many times the generator is asked again when the
+	// generated alias is taken or reserved
+	AliasRetries int
+	// ReservedAliases are neither accepted from clients nor generated,
+	// they are compared case-insensitively
+	ReservedAliases []string
+	// Dedupe is the default of Save for requests not choosing it explicitly
+	Dedupe bool
+}
+
+// NewUrlSaver creates the service. Aliases omitted by clients are made by
+// the generator
 func NewUrlSaver(
 	log *slog.Logger,
 	urlSaver SaverUrl,
 	urlProvider ProviderUrl,
 	urlUpdater UpdaterUrl,
+	shortener Shortener,
+	urlNormalizer UrlNormalizer,
+	pageTokens PageTokenCodec,
+	aliases AliasGenerator,
+	opts Options,
 ) *UrlSaver {
+	reserved := make(map[string]struct{}, len(opts.ReservedAliases))
+	for _, alias := range opts.ReservedAliases {
+		if alias != "" {
+			reserved[strings.ToLower(alias)] = struct{}{}
+		}
+	}
+
 	return &UrlSaver{
-		log:         log,
-		urlSaver:    urlSaver,
-		urlProvider: urlProvider,
-		urlUpdater:  urlUpdater,
+		log:             log,
+		urlSaver:        urlSaver,
+		urlProvider:     urlProvider,
+		urlUpdater:      urlUpdater,
+		shortener:       shortener,
+		urlNormalizer:   urlNormalizer,
+		pageTokens:      pageTokens,
+		maxPageSize:     opts.MaxPageSize,
+		aliases:         aliases,
+		aliasRetries:    opts.AliasRetries,
+		reservedAliases: reserved,
+		dedupe:          opts.Dedupe,
 	}
 }
 
 type SaverUrl interface {
-	SaveUrl(ctx context.Context, url, alias string) (urlID int64, err error)
+	SaveUrl(ctx context.Context, userID int64, url, alias string, meta entities.UrlMeta) (urlID int64, err error)
 }
 
 type ProviderUrl interface {
-	Url(ctx context.Context, alias string) (url entities.URL, err error)
-	UrlList(ctx context.Context, alias string) (urls []entities.URL, err error)
+	Url(ctx context.Context, userID int64, alias string) (url entities.URL, err error)
+	UrlByID(ctx context.Context, userID, urlID int64) (url entities.URL, err error)
+	UrlByURL(ctx context.Context, userID int64, targetURL string) (url entities.URL, err error)
+	PublicUrl(ctx context.Context, alias string) (url entities.URL, err error)
+	UrlList(
+		ctx context.Context,
+		userID int64,
+		filter entities.UrlFilter,
+		after *entities.Cursor,
+		limit int,
+	) (urls []entities.URL, err error)
 }
 
 type UpdaterUrl interface {
-	UpdateUrl(ctx context.Context, urlID int64, alias string) (err error)
-	RemoveUrl(ctx context.Context, alias string) (err error)
+	UpdateUrl(ctx context.Context, userID, urlID int64, url, alias string, meta entities.UrlMeta) (err error)
+	RemoveUrl(ctx context.Context, userID int64, alias string) (urlID int64, err error)
+	RestoreUrl(ctx context.Context, userID int64, alias string) (url entities.URL, err error)
+	RestoreUrlByID(ctx context.Context, userID, urlID int64) (url entities.URL, err error)
+}
+
+// PageTokenCodec converts list cursors of the user into opaque page tokens and back.
+// Scope identifies the filter of the list, token of one list is not valid for another
+type PageTokenCodec interface {
+	Encode(userID int64, scope string, cursor entities.Cursor) (token string)
+	Decode(userID int64, scope, token string) (cursor entities.Cursor, err error)
+}
+
+// Shortener is the remote url shortener service or its in-process replacement
+type Shortener interface {
+	ShortenURL(ctx context.Context, originalURL, alias string) (*urlshortener.ShortenResponse, error)
+}
+
+// UrlNormalizer validates url and returns its canonical form,
+// invalid url is reported as storage.ErrUrlIsInvalid
+type UrlNormalizer interface {
+	Normalize(rawURL string) (url string, err error)
 }
 
-func (u *UrlSaver) Save(ctx context.Context, url, aliasReq string) (urlID int64, aliasRes string, err error) {
+// AliasGenerator makes aliases for urls saved without one
+type AliasGenerator interface {
+	Generate() (alias string, err error)
+}
+
+// callerID returns ID of the user who made the request
+func callerID(ctx context.Context) (int64, error) {
+	userID, ok := userctx.UserID(ctx)
+	if !ok {
+		return 0, ErrUnauthenticated
+	}
+
+	return userID, nil
+}
+
+// Save saves url of the calling user. When aliasReq is empty the alias is
+// generated and returned as aliasRes. With dedupe such url already saved by
+// the user is returned instead of saving it again, nil dedupe means the
+// service default. Urls saved with aliasReq are never deduplicated, the
+// requested alias is saved or an error is returned
+func (u *UrlSaver) Save(
+	ctx context.Context,
+	url, aliasReq string,
+	meta entities.UrlMeta,
+	dedupe *bool,
+) (urlID int64, aliasRes string, err error) {
 	log := u.log.With(slog.String("op", opSave))
 	// TODO: Remove from protobuf file returning 3th parameters. Only 2 or less must be returnable
 	aliasRes = aliasReq
 
-	urlID, err = u.urlSaver.SaveUrl(ctx, url, aliasReq)
+	userID, err := callerID(ctx)
+	if err != nil {
+		return 0, "", sl.Wrap(opSave, err)
+	}
+
+	if meta.ExpiresAt != nil && !meta.ExpiresAt.IsZero() && !meta.ExpiresAt.After(time.Now()) {
+		return 0, "", sl.Wrap(opSave, ErrInvalidExpiration)
+	}
+
+	if url, err = u.urlNormalizer.Normalize(url); err != nil {
+		return 0, "", sl.Wrap(opSave, err)
+	}
+
+	if aliasReq == "" && ((dedupe == nil && u.dedupe) || (dedupe != nil && *dedupe)) {
+		existing, err := u.urlProvider.UrlByURL(ctx, userID, url)
+		if err == nil {
+			return existing.ID, existing.Alias, nil
+		}
+		if !errors.Is(err, storage.ErrAliasNotFound) {
+			log.Error("failed to find saved url", sl.Err(err), sl.OpStack(err))
+
+			return 0, "", sl.Wrap(opSave, err)
+		}
+	}
+
+	if aliasReq == "" {
+		return u.saveGenerated(ctx, log, userID, url, meta)
+	}
+
+	aliasRes, err = u.shorten(ctx, log, url, aliasReq)
+	if err != nil {
+		return 0, "", sl.Wrap(opSave, err)
+	}
+
+	urlID, err = u.urlSaver.SaveUrl(ctx, userID, url, aliasRes, meta)
 	if err != nil {
 		if errors.Is(err, storage.ErrAliasExists) {
-			return 0, "", sl.ErrUpLevel(opSave, ErrAliasExists.Error())
+			return 0, "", sl.Wrap(opSave, ErrAliasExists)
 		}
-		log.Error("failed to save url", sl.Err(err))
+		log.Error("failed to save url", sl.Err(err), sl.OpStack(err))
 
-		return 0, "", sl.ErrUpLevel(opSave, err.Error())
+		return 0, "", sl.Wrap(opSave, err)
 	}
 
 	return
 }
 
+// saveGenerated saves url under generated alias, taken and reserved
+// aliases are skipped until retries are exhausted
+func (u *UrlSaver) saveGenerated(
+	ctx context.Context,
+	log *slog.Logger,
+	userID int64,
+	url string,
+	meta entities.UrlMeta,
+) (urlID int64, alias string, err error) {
+	for attempt := 0; attempt <= u.aliasRetries; attempt++ {
+		candidate, err := u.aliases.Generate()
+		if err != nil {
+			log.Error("failed to generate alias", sl.Err(err))
+
+			return 0, "", sl.Wrap(opSave, err)
+		}
+
+		alias, err := u.shorten(ctx, log, url, candidate)
+		if errors.Is(err, ErrAliasReserved) {
+			continue
+		}
+		if err != nil {
+			return 0, "", sl.Wrap(opSave, err)
+		}
+
+		urlID, err = u.urlSaver.SaveUrl(ctx, userID, url, alias, meta)
+		if err == nil {
+			return urlID, alias, nil
+		}
+		if !errors.Is(err, storage.ErrAliasExists) {
+			log.Error("failed to save url", sl.Err(err), sl.OpStack(err))
+
+			return 0, "", sl.Wrap(opSave, err)
+		}
+		log.Debug("generated alias is taken", slog.String("alias", alias), slog.Int("attempt", attempt))
+	}
+
+	log.Warn("no free alias after retries", slog.Int("retries", u.aliasRetries))
+
+	return 0, "", sl.Wrap(opSave, ErrAliasGeneration)
+}
+
+// Get returns url of the calling user by alias. Without user in the context
+// the alias is resolved among urls of all users, it is used by redirects
 func (u *UrlSaver) Get(ctx context.Context, aliasReq string) (url, aliasRes string, urlID int64, err error) {
-	// TODO: implement domain zone
-	panic("implement me")
+	log := u.log.With(slog.String("op", opGet))
+
+	var urlObj entities.URL
+	if userID, ok := userctx.UserID(ctx); ok {
+		urlObj, err = u.urlProvider.Url(ctx, userID, aliasReq)
+	} else {
+		urlObj, err = u.urlProvider.PublicUrl(ctx, aliasReq)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			return "", "", 0, sl.Wrap(opGet, ErrAliasNotFound)
+		}
+		log.Error("failed to get url", sl.Err(err), sl.OpStack(err))
+
+		return "", "", 0, sl.Wrap(opGet, err)
+	}
+
+	return urlObj.URL, urlObj.Alias, urlObj.ID, nil
 }
 
-func (u *UrlSaver) UpdateByID(ctx context.Context, urlID int64, newURL, newAliasReq string) (success bool, newAliasRes string, err error) {
-	// TODO: implement domain zone
-	panic("implement me")
+// UpdateByID updates url of the calling user. Empty newAliasReq keeps the
+// current alias, otherwise it goes through the same pipeline as on Save.
+// The alias in effect after the update is returned as newAliasRes
+func (u *UrlSaver) UpdateByID(
+	ctx context.Context,
+	urlID int64,
+	newURL, newAliasReq string,
+	meta entities.UrlMeta,
+) (success bool, newAliasRes string, err error) {
+	log := u.log.With(slog.String("op", opUpdateByID))
+
+	userID, err := callerID(ctx)
+	if err != nil {
+		return false, "", sl.Wrap(opUpdateByID, err)
+	}
+
+	if meta.ExpiresAt != nil && !meta.ExpiresAt.IsZero() && !meta.ExpiresAt.After(time.Now()) {
+		return false, "", sl.Wrap(opUpdateByID, ErrInvalidExpiration)
+	}
+
+	urlObj, err := u.urlProvider.UrlByID(ctx, userID, urlID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			return false, "", sl.Wrap(opUpdateByID, ErrAliasNotFound)
+		}
+		log.Error("failed to get url", sl.Err(err), sl.OpStack(err))
+
+		return false, "", sl.Wrap(opUpdateByID, err)
+	}
+
+	if newAliasRes, err = u.update(ctx, log, userID, urlObj, newURL, newAliasReq, meta); err != nil {
+		return false, "", sl.Wrap(opUpdateByID, err)
+	}
+
+	return true, newAliasRes, nil
 }
 
-func (u *UrlSaver) UpdateByAlias(ctx context.Context, alias, newURL, newAliasReq string) (success bool, newAliasRes string, err error) {
-	// TODO: implement domain zone
-	panic("implement me")
+// UpdateByAlias updates url of the calling user found by its alias the same
+// way as UpdateByID
+func (u *UrlSaver) UpdateByAlias(
+	ctx context.Context,
+	alias, newURL, newAliasReq string,
+	meta entities.UrlMeta,
+) (success bool, newAliasRes string, err error) {
+	log := u.log.With(slog.String("op", opUpdateByAlias))
+
+	userID, err := callerID(ctx)
+	if err != nil {
+		return false, "", sl.Wrap(opUpdateByAlias, err)
+	}
+
+	if meta.ExpiresAt != nil && !meta.ExpiresAt.IsZero() && !meta.ExpiresAt.After(time.Now()) {
+		return false, "", sl.Wrap(opUpdateByAlias, ErrInvalidExpiration)
+	}
+
+	urlObj, err := u.urlProvider.Url(ctx, userID, alias)
+	if err != nil {
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			return false, "", sl.Wrap(opUpdateByAlias, ErrAliasNotFound)
+		}
+		log.Error("failed to get url", sl.Err(err), sl.OpStack(err))
+
+		return false, "", sl.Wrap(opUpdateByAlias, err)
+	}
+
+	if newAliasRes, err = u.update(ctx, log, userID, urlObj, newURL, newAliasReq, meta); err != nil {
+		return false, "", sl.Wrap(opUpdateByAlias, err)
+	}
+
+	return true, newAliasRes, nil
+}
+
+// update sets new url, alias and meta of the stored url and returns the
+// alias in effect. A new alias goes through shorten with the url it will
+// point to, the new one or the stored one
+func (u *UrlSaver) update(
+	ctx context.Context,
+	log *slog.Logger,
+	userID int64,
+	urlObj entities.URL,
+	newURL, newAliasReq string,
+	meta entities.UrlMeta,
+) (alias string, err error) {
+	if newURL != "" {
+		if newURL, err = u.urlNormalizer.Normalize(newURL); err != nil {
+			return "", err
+		}
+	}
+
+	if newAliasReq != "" {
+		target := newURL
+		if target == "" {
+			target = urlObj.URL
+		}
+		if newAliasReq, err = u.shorten(ctx, log, target, newAliasReq); err != nil {
+			return "", err
+		}
+	}
+
+	if err = u.urlUpdater.UpdateUrl(ctx, userID, urlObj.ID, newURL, newAliasReq, meta); err != nil {
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			return "", ErrAliasNotFound
+		}
+		if errors.Is(err, storage.ErrAliasExists) {
+			return "", ErrAliasExists
+		}
+		log.Error("failed to update url", sl.Err(err), sl.OpStack(err))
+
+		return "", err
+	}
+
+	if newAliasReq == "" {
+		return urlObj.Alias, nil
+	}
+
+	return newAliasReq, nil
 }
 
 func (u *UrlSaver) RemoveByID(ctx context.Context, urlID int64) (success bool, removedUrlID int64, err error) {
-	// TODO: implement domain zone
-	panic("implement me")
+	log := u.log.With(slog.String("op", opRemoveByID))
+
+	userID, err := callerID(ctx)
+	if err != nil {
+		return false, 0, sl.Wrap(opRemoveByID, err)
+	}
+
+	urlObj, err := u.urlProvider.UrlByID(ctx, userID, urlID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			return false, 0, sl.Wrap(opRemoveByID, ErrAliasNotFound)
+		}
+		log.Error("failed to get url", sl.Err(err), sl.OpStack(err))
+
+		return false, 0, sl.Wrap(opRemoveByID, err)
+	}
+
+	return u.remove(ctx, log, opRemoveByID, userID, urlObj.Alias)
 }
 
 func (u *UrlSaver) RemoveByAlias(ctx context.Context, aliasReq string) (success bool, removedUrlID int64, err error) {
-	// TODO: implement domain zone
-	panic("implement me")
+	log := u.log.With(slog.String("op", opRemoveByAlias))
+
+	userID, err := callerID(ctx)
+	if err != nil {
+		return false, 0, sl.Wrap(opRemoveByAlias, err)
+	}
+
+	return u.remove(ctx, log, opRemoveByAlias, userID, aliasReq)
 }
 
-func (u *UrlSaver) List(ctx context.Context, pageToken string, pageSize int32) (URLs []*urlsv1.UrlItem, nextPageToken string, err error) {
-	// TODO: implement domain zone
-	panic("implement me")
+func (u *UrlSaver) remove(
+	ctx context.Context,
+	log *slog.Logger,
+	op string,
+	userID int64,
+	alias string,
+) (success bool, removedUrlID int64, err error) {
+	removedUrlID, err = u.urlUpdater.RemoveUrl(ctx, userID, alias)
+	if err != nil {
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			return false, 0, sl.Wrap(op, ErrAliasNotFound)
+		}
+		log.Error("failed to remove url", sl.Err(err), sl.OpStack(err))
+
+		return false, 0, sl.Wrap(op, err)
+	}
+
+	return true, removedUrlID, nil
+}
+
+// List returns one page of urls of the calling user matching the filter.
+// Page size is limited by the max page size, empty token means the first page
+func (u *UrlSaver) List(
+	ctx context.Context,
+	filter entities.UrlFilter,
+	pageToken string,
+	pageSize int32,
+) (URLs []*urlsv1.UrlItem, nextPageToken string, err error) {
+	log := u.log.With(slog.String("op", opList))
+
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, "", sl.Wrap(opList, err)
+	}
+
+	scope := filterScope(filter)
+
+	var after *entities.Cursor
+	if pageToken != "" {
+		cursor, err := u.pageTokens.Decode(userID, scope, pageToken)
+		if err != nil {
+			return nil, "", sl.Wrap(opList, ErrInvalidPageToken)
+		}
+		after = &cursor
+	}
+
+	limit := int(pageSize)
+	if limit <= 0 || limit > u.maxPageSize {
+		limit = u.maxPageSize
+	}
+
+	// one more url tells whether there is the next page
+	urls, err := u.urlProvider.UrlList(ctx, userID, filter, after, limit+1)
+	if err != nil {
+		log.Error("failed to list urls", sl.Err(err), sl.OpStack(err))
+
+		return nil, "", sl.Wrap(opList, err)
+	}
+
+	if len(urls) > limit {
+		urls = urls[:limit]
+		last := urls[limit-1]
+		nextPageToken = u.pageTokens.Encode(userID, scope, entities.Cursor{
+			CreatedAt: last.CreatedAt,
+			UpdatedAt: last.UpdatedAt,
+			Alias:     last.Alias,
+			ID:        last.ID,
+		})
+	}
+
+	URLs = make([]*urlsv1.UrlItem, 0, len(urls))
+	for _, url := range urls {
+		URLs = append(URLs, &urlsv1.UrlItem{
+			UrlId:     url.ID,
+			Url:       url.URL,
+			Alias:     url.Alias,
+			CreatedAt: url.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return
+}
+
+// filterScope returns string identifying the filter, page tokens are bound to it
+func filterScope(filter entities.UrlFilter) string {
+	timeKey := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+
+	return strings.Join([]string{
+		filter.Domain,
+		filter.AliasPrefix,
+		filter.Tag,
+		filter.Query,
+		timeKey(filter.CreatedFrom),
+		timeKey(filter.CreatedTo),
+		timeKey(filter.UpdatedFrom),
+		timeKey(filter.UpdatedTo),
+		string(filter.SortBy),
+		strconv.FormatBool(filter.Descending),
+	}, "\x00")
+}
+
+// RestoreByID takes removed url of the calling user out of the trash.
+// Urls already purged from the trash are not found
+func (u *UrlSaver) RestoreByID(ctx context.Context, urlID int64) (url, alias string, restoredUrlID int64, err error) {
+	log := u.log.With(slog.String("op", opRestoreByID))
+
+	userID, err := callerID(ctx)
+	if err != nil {
+		return "", "", 0, sl.Wrap(opRestoreByID, err)
+	}
+
+	urlObj, err := u.urlUpdater.RestoreUrlByID(ctx, userID, urlID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			return "", "", 0, sl.Wrap(opRestoreByID, ErrAliasNotFound)
+		}
+		log.Error("failed to restore url", sl.Err(err), sl.OpStack(err))
+
+		return "", "", 0, sl.Wrap(opRestoreByID, err)
+	}
+
+	return urlObj.URL, urlObj.Alias, urlObj.ID, nil
+}
+
+func (u *UrlSaver) RestoreByAlias(ctx context.Context, aliasReq string) (url, alias string, restoredUrlID int64, err error) {
+	log := u.log.With(slog.String("op", opRestoreByAlias))
+
+	userID, err := callerID(ctx)
+	if err != nil {
+		return "", "", 0, sl.Wrap(opRestoreByAlias, err)
+	}
+
+	urlObj, err := u.urlUpdater.RestoreUrl(ctx, userID, aliasReq)
+	if err != nil {
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			return "", "", 0, sl.Wrap(opRestoreByAlias, ErrAliasNotFound)
+		}
+		log.Error("failed to restore url", sl.Err(err), sl.OpStack(err))
+
+		return "", "", 0, sl.Wrap(opRestoreByAlias, err)
+	}
+
+	return urlObj.URL, urlObj.Alias, urlObj.ID, nil
 }
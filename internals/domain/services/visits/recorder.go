@@ -0,0 +1,177 @@
+package visits
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const (
+	opFlush = "services.visits.Recorder.flush"
+
+	// flushTimeout bounds saving of one batch
+	flushTimeout = 5 * time.Second
+
+	maxReferrerLen  = 1024
+	maxUserAgentLen = 512
+)
+
+type SaverVisit interface {
+	SaveVisits(ctx context.Context, visits []entities.Visit) error
+}
+
+// Recorder saves visits in the background. Record only queues the visit,
+// they are saved in batches when the batch is full or the flush interval
+// passes. When the queue is full visits are dropped instead of slowing
+// down redirects
+type Recorder struct {
+	log           *slog.Logger
+	visitSaver    SaverVisit
+	ipSalt        []byte
+	batchSize     int
+	flushInterval time.Duration
+
+	queue   chan entities.Visit
+	dropped atomic.Int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRecorder creates recorder queueing up to queueSize visits. IP addresses
+// are hashed with ipSalt, so visitors can be counted but not identified
+func NewRecorder(
+	log *slog.Logger,
+	visitSaver SaverVisit,
+	ipSalt []byte,
+	queueSize int,
+	batchSize int,
+	flushInterval time.Duration,
+) *Recorder {
+	return &Recorder{
+		log:           log,
+		visitSaver:    visitSaver,
+		ipSalt:        ipSalt,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		queue:         make(chan entities.Visit, queueSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Record queues the visit of the url, it never blocks
+func (r *Recorder) Record(urlID int64, alias, referrer, userAgent, ip string) {
+	visit := entities.Visit{
+		URLID:     urlID,
+		Alias:     alias,
+		VisitedAt: time.Now().UTC(),
+		Referrer:  truncate(referrer, maxReferrerLen),
+		UserAgent: truncate(userAgent, maxUserAgentLen),
+		IPHash:    r.hashIP(ip),
+	}
+
+	select {
+	case r.queue <- visit:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// MustStart saves queued visits until Stop is called
+func (r *Recorder) MustStart() {
+	defer close(r.done)
+
+	r.log.Info("Visit recorder started", slog.Int("batch_size", r.batchSize), slog.Duration("flush_interval", r.flushInterval))
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entities.Visit, 0, r.batchSize)
+	for {
+		select {
+		case visit := <-r.queue:
+			batch = append(batch, visit)
+			if len(batch) >= r.batchSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-r.stop:
+			// visits queued before Stop are still saved
+			for {
+				select {
+				case visit := <-r.queue:
+					batch = append(batch, visit)
+					if len(batch) >= r.batchSize {
+						batch = r.flush(batch)
+					}
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stop saves the queued visits and waits for the recorder to exit.
+// Redirects must be stopped first, visits recorded later are lost
+func (r *Recorder) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// flush saves the batch and returns it emptied for reuse
+func (r *Recorder) flush(batch []entities.Visit) []entities.Visit {
+	log := r.log.With(slog.String("op", opFlush))
+
+	if dropped := r.dropped.Swap(0); dropped > 0 {
+		log.Warn("visit queue is full, visits dropped", slog.Int64("count", dropped))
+	}
+
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	if err := r.visitSaver.SaveVisits(ctx, batch); err != nil {
+		log.Error("failed to save visits", slog.Int("count", len(batch)), sl.Err(err), sl.OpStack(err))
+	}
+
+	return batch[:0]
+}
+
+func (r *Recorder) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, r.ipSalt)
+	mac.Write([]byte(ip))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// truncate cuts s to at most n bytes without splitting a rune
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,275 @@
+package visits_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/domain/services/visits"
+)
+
+// waitTimeout bounds waiting for a flush which is expected to happen
+const waitTimeout = 5 * time.Second
+
+// batchSaver keeps copies of the saved batches, the recorder reuses them.
+// Every batch is also sent to saved when it is not nil
+type batchSaver struct {
+	mu      sync.Mutex
+	batches [][]entities.Visit
+	saved   chan []entities.Visit
+	err     error
+}
+
+func (s *batchSaver) SaveVisits(_ context.Context, batch []entities.Visit) error {
+	batch = slices.Clone(batch)
+
+	s.mu.Lock()
+	s.batches = append(s.batches, batch)
+	s.mu.Unlock()
+
+	if s.saved != nil {
+		s.saved <- batch
+	}
+
+	return s.err
+}
+
+// urlIDs returns IDs of the saved visits in the order of saving
+func (s *batchSaver) urlIDs() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []int64
+	for _, batch := range s.batches {
+		for _, visit := range batch {
+			ids = append(ids, visit.URLID)
+		}
+	}
+
+	return ids
+}
+
+func (s *batchSaver) sizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sizes := make([]int, 0, len(s.batches))
+	for _, batch := range s.batches {
+		sizes = append(sizes, len(batch))
+	}
+
+	return sizes
+}
+
+func newRecorder(saver visits.SaverVisit, queueSize, batchSize int, flushInterval time.Duration) *visits.Recorder {
+	return visits.NewRecorder(slog.New(slog.DiscardHandler), saver, []byte("salt"), queueSize, batchSize, flushInterval)
+}
+
+// record records visits of urls from 1 to n
+func record(r *visits.Recorder, n int) {
+	for id := 1; id <= n; id++ {
+		r.Record(int64(id), "alias", "", "", "")
+	}
+}
+
+// sequence returns IDs from 1 to n
+func sequence(n int) []int64 {
+	ids := make([]int64, 0, n)
+	for id := 1; id <= n; id++ {
+		ids = append(ids, int64(id))
+	}
+
+	return ids
+}
+
+func wait(t *testing.T, saved <-chan []entities.Visit) []entities.Visit {
+	t.Helper()
+
+	select {
+	case batch := <-saved:
+		return batch
+	case <-time.After(waitTimeout):
+		t.Fatal("visits were not flushed")
+		return nil
+	}
+}
+
+func TestFlushOnBatchSize(t *testing.T) {
+	saver := &batchSaver{saved: make(chan []entities.Visit, 10)}
+	r := newRecorder(saver, 100, 3, time.Hour)
+	go r.MustStart()
+
+	record(r, 7)
+
+	for range 2 {
+		if batch := wait(t, saver.saved); len(batch) != 3 {
+			t.Fatalf("flushed %d visits, want a full batch of 3", len(batch))
+		}
+	}
+
+	r.Stop()
+
+	if got := saver.sizes(); !slices.Equal(got, []int{3, 3, 1}) {
+		t.Errorf("batch sizes = %v, want [3 3 1]", got)
+	}
+	if got := saver.urlIDs(); !slices.Equal(got, sequence(7)) {
+		t.Errorf("saved visits = %v, want %v", got, sequence(7))
+	}
+}
+
+func TestFlushOnInterval(t *testing.T) {
+	saver := &batchSaver{saved: make(chan []entities.Visit, 10)}
+	r := newRecorder(saver, 100, 100, 10*time.Millisecond)
+	go r.MustStart()
+	defer r.Stop()
+
+	record(r, 2)
+
+	var ids []int64
+	for len(ids) < 2 {
+		for _, visit := range wait(t, saver.saved) {
+			ids = append(ids, visit.URLID)
+		}
+	}
+	if !slices.Equal(ids, sequence(2)) {
+		t.Errorf("saved visits = %v, want %v", ids, sequence(2))
+	}
+}
+
+func TestStopFlushesQueued(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		visits    int
+		wantSizes []int
+	}{
+		{name: "nothing recorded", batchSize: 10, visits: 0, wantSizes: []int{}},
+		{name: "partial batch", batchSize: 10, visits: 4, wantSizes: []int{4}},
+		{name: "full batches", batchSize: 10, visits: 20, wantSizes: []int{10, 10}},
+		{name: "many batches", batchSize: 100, visits: 250, wantSizes: []int{100, 100, 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saver := &batchSaver{}
+			r := newRecorder(saver, 1000, tt.batchSize, time.Hour)
+
+			// visits are queued before the recorder runs, so all of them
+			// are still in the queue when Stop is called
+			record(r, tt.visits)
+			go r.MustStart()
+			r.Stop()
+
+			if got := saver.sizes(); !slices.Equal(got, tt.wantSizes) {
+				t.Errorf("batch sizes = %v, want %v", got, tt.wantSizes)
+			}
+			if got := saver.urlIDs(); !slices.Equal(got, sequence(tt.visits)) {
+				t.Errorf("saved %d visits, want %d in order", len(got), tt.visits)
+			}
+		})
+	}
+}
+
+func TestRecordWhileRunning(t *testing.T) {
+	saver := &batchSaver{}
+	r := newRecorder(saver, 10000, 7, time.Millisecond)
+	go r.MustStart()
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			record(r, 100)
+		}()
+	}
+	wg.Wait()
+	r.Stop()
+
+	if got := len(saver.urlIDs()); got != 1000 {
+		t.Errorf("saved %d visits, want 1000", got)
+	}
+	for _, size := range saver.sizes() {
+		if size < 1 || size > 7 {
+			t.Errorf("batch of %d visits, want 1 to 7", size)
+		}
+	}
+}
+
+func TestFullQueueDropsVisits(t *testing.T) {
+	saver := &batchSaver{}
+	r := newRecorder(saver, 2, 10, time.Hour)
+
+	record(r, 5)
+	go r.MustStart()
+	r.Stop()
+
+	if got := saver.urlIDs(); !slices.Equal(got, sequence(2)) {
+		t.Errorf("saved visits = %v, want the first 2 only", got)
+	}
+}
+
+func TestFailedFlushKeepsRecording(t *testing.T) {
+	saver := &batchSaver{err: errors.New("storage is unavailable")}
+	r := newRecorder(saver, 100, 2, time.Hour)
+	go r.MustStart()
+
+	record(r, 5)
+	r.Stop()
+
+	if got := saver.urlIDs(); !slices.Equal(got, sequence(5)) {
+		t.Errorf("visits passed to the storage = %v, want %v", got, sequence(5))
+	}
+}
+
+func TestRecordedVisit(t *testing.T) {
+	saver := &batchSaver{}
+	r := newRecorder(saver, 10, 10, time.Hour)
+
+	longReferrer := "https://example.com/" + strings.Repeat("é", 1000)
+	before := time.Now()
+	r.Record(1, "first", longReferrer, strings.Repeat("a", 600), "192.0.2.1")
+	r.Record(2, "second", "https://example.com", "agent", "192.0.2.1")
+	r.Record(3, "third", "", "", "192.0.2.2")
+	r.Record(4, "fourth", "", "", "")
+	go r.MustStart()
+	r.Stop()
+
+	if len(saver.batches) != 1 || len(saver.batches[0]) != 4 {
+		t.Fatalf("batches = %v, want one of 4 visits", saver.sizes())
+	}
+	saved := saver.batches[0]
+
+	first := saved[0]
+	if first.URLID != 1 || first.Alias != "first" {
+		t.Errorf("visit = %d %q, want 1 \"first\"", first.URLID, first.Alias)
+	}
+	if first.VisitedAt.Before(before.Add(-time.Second)) || first.VisitedAt.Location() != time.UTC {
+		t.Errorf("VisitedAt = %v, want current UTC time", first.VisitedAt)
+	}
+	if len(first.Referrer) > 1024 || !strings.HasPrefix(longReferrer, first.Referrer) || !utf8.ValidString(first.Referrer) {
+		t.Errorf("referrer of %d bytes is not truncated to 1024 on a rune boundary", len(first.Referrer))
+	}
+	if len(first.UserAgent) != 512 {
+		t.Errorf("user agent of %d bytes, want 512", len(first.UserAgent))
+	}
+
+	if first.IPHash == "" || strings.Contains(first.IPHash, "192.0.2.1") {
+		t.Errorf("IPHash = %q, want a hash of the address", first.IPHash)
+	}
+	if saved[1].IPHash != first.IPHash {
+		t.Error("the same address is hashed differently")
+	}
+	if saved[2].IPHash == first.IPHash {
+		t.Error("different addresses have the same hash")
+	}
+	if saved[3].IPHash != "" {
+		t.Errorf("IPHash = %q of an unknown address, want empty", saved[3].IPHash)
+	}
+}
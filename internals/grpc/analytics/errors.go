@@ -0,0 +1,33 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nhassl3/url-saver/internals/domain/services/analytics"
+	"github.com/nhassl3/url-saver/internals/grpc/grpcerr"
+	"google.golang.org/grpc/codes"
+)
+
+// statusError translates an error of the analytics service into gRPC status.
+// Messages are fixed per error, the chain of the error is left for logs
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, analytics.ErrAliasNotFound):
+		return grpcerr.Resource(codes.NotFound, grpcerr.ReasonAliasNotFound, "alias", analytics.ErrAliasNotFound)
+	case errors.Is(err, analytics.ErrInvalidBucket):
+		return grpcerr.Field(fieldBucket, grpcerr.ReasonInvalidArgument, analytics.ErrInvalidBucket)
+	case errors.Is(err, analytics.ErrInvalidRange):
+		return grpcerr.Field(fieldFrom, grpcerr.ReasonInvalidArgument, analytics.ErrInvalidRange)
+	case errors.Is(err, analytics.ErrTooManyBuckets):
+		return grpcerr.Field(fieldFrom, grpcerr.ReasonInvalidArgument, analytics.ErrTooManyBuckets)
+	case errors.Is(err, analytics.ErrInvalidLimit):
+		return grpcerr.Field(fieldLimit, grpcerr.ReasonInvalidArgument, analytics.ErrInvalidLimit)
+	case errors.Is(err, analytics.ErrUnauthenticated):
+		return grpcerr.WithDetails(codes.Unauthenticated, analytics.ErrUnauthenticated.Error(), grpcerr.ReasonUnauthenticated)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return grpcerr.Context(err)
+	default:
+		return grpcerr.Internal()
+	}
+}
@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/grpc/grpcerr"
+	"github.com/nhassl3/url-saver/internals/grpc/structmsg"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	fieldAlias  = "alias"
+	fieldFrom   = "from"
+	fieldTo     = "to"
+	fieldBucket = "bucket"
+	fieldLimit  = "limit"
+)
+
+// aliasRange reads the required alias and the time range of the request
+func aliasRange(in *structpb.Struct) (alias string, from, to time.Time, err error) {
+	alias = structmsg.String(in, fieldAlias)
+	if alias == "" {
+		return "", time.Time{}, time.Time{}, grpcerr.InvalidArgument(fieldAlias, "is required")
+	}
+
+	from, to, err = timeRange(in)
+	if err != nil {
+		return "", time.Time{}, time.Time{}, err
+	}
+
+	return
+}
+
+// timeRange reads the time range of the request, omitted ends are zero
+func timeRange(in *structpb.Struct) (from, to time.Time, err error) {
+	if from, err = timeField(in, fieldFrom); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if to, err = timeField(in, fieldTo); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return
+}
+
+func timeField(in *structpb.Struct, name string) (time.Time, error) {
+	value := structmsg.String(in, name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, grpcerr.InvalidArgument(name, "must be a time in RFC 3339 format")
+	}
+
+	return t, nil
+}
@@ -0,0 +1,197 @@
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/grpc/structmsg"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The analytics service is not a part of the contract yet, it is specified
+// in api/proto/urlsaver/url_saver_analytics.proto. Until the contract has it,
+// it is described by hand and its messages are google.protobuf.Struct with
+// the fields of that spec, so clients call it by the full method name.
+// Requests may carry:
+//
+//	alias  - alias of the url, required by all methods but TopLinks
+//	from   - start of the range in RFC 3339, the beginning when omitted
+//	to     - end of the range in RFC 3339, now when omitted
+//	bucket - hour, day or week, only for Series
+//	limit  - length of top lists, 10 when omitted
+const (
+	ServiceName             = "UrlSaver.UrlSaverAnalytics"
+	serviceMetadataFileName = "url_saver_analytics"
+
+	TotalsFullMethodName        = "/" + ServiceName + "/Totals"
+	SeriesFullMethodName        = "/" + ServiceName + "/Series"
+	TopReferrersFullMethodName  = "/" + ServiceName + "/TopReferrers"
+	TopUserAgentsFullMethodName = "/" + ServiceName + "/TopUserAgents"
+	TopLinksFullMethodName      = "/" + ServiceName + "/TopLinks"
+)
+
+type Analytics interface {
+	Totals(ctx context.Context, alias string, from, to time.Time) (stats entities.VisitStats, err error)
+	Series(
+		ctx context.Context,
+		alias string,
+		bucket entities.Bucket,
+		from, to time.Time,
+	) (series []entities.VisitCount, err error)
+	TopReferrers(ctx context.Context, alias string, from, to time.Time, limit int) (top []entities.TopValue, err error)
+	TopUserAgents(ctx context.Context, alias string, from, to time.Time, limit int) (top []entities.TopValue, err error)
+	TopLinks(ctx context.Context, from, to time.Time, limit int) (top []entities.UrlVisits, err error)
+}
+
+type AnalyticsServer interface {
+	Totals(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+	Series(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+	TopReferrers(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+	TopUserAgents(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+	TopLinks(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*AnalyticsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Totals", Handler: structmsg.UnaryHandler(TotalsFullMethodName, AnalyticsServer.Totals)},
+		{MethodName: "Series", Handler: structmsg.UnaryHandler(SeriesFullMethodName, AnalyticsServer.Series)},
+		{MethodName: "TopReferrers", Handler: structmsg.UnaryHandler(TopReferrersFullMethodName, AnalyticsServer.TopReferrers)},
+		{MethodName: "TopUserAgents", Handler: structmsg.UnaryHandler(TopUserAgentsFullMethodName, AnalyticsServer.TopUserAgents)},
+		{MethodName: "TopLinks", Handler: structmsg.UnaryHandler(TopLinksFullMethodName, AnalyticsServer.TopLinks)},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: serviceMetadataFileName,
+}
+
+type ServerAPI struct {
+	analytics Analytics
+}
+
+// Register registers the analytics service on the gRPC server
+func Register(gRPC *grpc.Server, analytics Analytics) {
+	gRPC.RegisterService(&serviceDesc, &ServerAPI{analytics: analytics})
+}
+
+// Totals returns visits and unique visitors of the alias
+func (api *ServerAPI) Totals(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	alias, from, to, err := aliasRange(in)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := api.analytics.Totals(ctx, alias, from, to)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return structmsg.Response(map[string]any{
+		"alias":    alias,
+		"visits":   stats.Visits,
+		"visitors": stats.Visitors,
+	})
+}
+
+// Series returns visits and unique visitors of the alias per bucket
+func (api *ServerAPI) Series(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	alias, from, to, err := aliasRange(in)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := api.analytics.Series(ctx, alias, entities.Bucket(structmsg.String(in, fieldBucket)), from, to)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	buckets := make([]any, 0, len(series))
+	for _, count := range series {
+		buckets = append(buckets, map[string]any{
+			"start":    count.Start.Format(time.RFC3339),
+			"visits":   count.Visits,
+			"visitors": count.Visitors,
+		})
+	}
+
+	return structmsg.Response(map[string]any{
+		"alias":   alias,
+		"buckets": buckets,
+	})
+}
+
+// TopReferrers returns referrers of the alias with most visits
+func (api *ServerAPI) TopReferrers(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	return api.topValues(ctx, in, api.analytics.TopReferrers)
+}
+
+// TopUserAgents returns user agents of the alias with most visits
+func (api *ServerAPI) TopUserAgents(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	return api.topValues(ctx, in, api.analytics.TopUserAgents)
+}
+
+func (api *ServerAPI) topValues(
+	ctx context.Context,
+	in *structpb.Struct,
+	top func(ctx context.Context, alias string, from, to time.Time, limit int) ([]entities.TopValue, error),
+) (*structpb.Struct, error) {
+	alias, from, to, err := aliasRange(in)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := structmsg.Int(in, fieldLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := top(ctx, alias, from, to, limit)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	items := make([]any, 0, len(values))
+	for _, value := range values {
+		items = append(items, map[string]any{
+			"value":  value.Value,
+			"visits": value.Visits,
+		})
+	}
+
+	return structmsg.Response(map[string]any{
+		"alias": alias,
+		"items": items,
+	})
+}
+
+// TopLinks returns urls of the calling user with most visits
+func (api *ServerAPI) TopLinks(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	from, to, err := timeRange(in)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := structmsg.Int(in, fieldLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := api.analytics.TopLinks(ctx, from, to, limit)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	links := make([]any, 0, len(urls))
+	for _, url := range urls {
+		links = append(links, map[string]any{
+			"url_id": url.URLID,
+			"url":    url.URL,
+			"alias":  url.Alias,
+			"visits": url.Visits,
+		})
+	}
+
+	return structmsg.Response(map[string]any{"links": links})
+}
@@ -0,0 +1,29 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nhassl3/url-saver/internals/domain/services/apikeys"
+	"github.com/nhassl3/url-saver/internals/grpc/grpcerr"
+	"google.golang.org/grpc/codes"
+)
+
+// statusError translates an error of the api keys service into gRPC status.
+// Messages are fixed per error, the chain of the error is left for logs
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, apikeys.ErrApiKeyNotFound):
+		return grpcerr.Resource(codes.NotFound, grpcerr.ReasonApiKeyNotFound, "api key", apikeys.ErrApiKeyNotFound)
+	case errors.Is(err, apikeys.ErrInvalidName):
+		return grpcerr.Field(fieldName, grpcerr.ReasonInvalidArgument, apikeys.ErrInvalidName)
+	case errors.Is(err, apikeys.ErrApiKeyForbidden):
+		return grpcerr.WithDetails(codes.PermissionDenied, apikeys.ErrApiKeyForbidden.Error(), grpcerr.ReasonApiKeyForbidden)
+	case errors.Is(err, apikeys.ErrUnauthenticated):
+		return grpcerr.WithDetails(codes.Unauthenticated, apikeys.ErrUnauthenticated.Error(), grpcerr.ReasonUnauthenticated)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return grpcerr.Context(err)
+	default:
+		return grpcerr.Internal()
+	}
+}
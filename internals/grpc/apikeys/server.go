@@ -0,0 +1,117 @@
+package apikeys
+
+import (
+	"context"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/grpc/grpcerr"
+	"github.com/nhassl3/url-saver/internals/grpc/structmsg"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The api keys service is not a part of the contract yet, it is specified
+// in api/proto/urlsaver/url_saver_apikeys.proto. Until the contract has it,
+// it is described by hand and its messages are google.protobuf.Struct with
+// the fields of that spec, so clients call it by the full method name.
+// It must be called with a bearer token, requests authenticated by an api
+// key are PERMISSION_DENIED
+const (
+	ServiceName             = "UrlSaver.UrlSaverApiKeys"
+	serviceMetadataFileName = "url_saver_apikeys"
+
+	CreateFullMethodName = "/" + ServiceName + "/Create"
+	ListFullMethodName   = "/" + ServiceName + "/List"
+	RevokeFullMethodName = "/" + ServiceName + "/Revoke"
+
+	fieldName = "name"
+	fieldID   = "id"
+)
+
+type ApiKeys interface {
+	Create(ctx context.Context, name string) (key string, keyID int64, err error)
+	List(ctx context.Context) (keys []entities.ApiKey, err error)
+	Revoke(ctx context.Context, keyID int64) (err error)
+}
+
+type ApiKeysServer interface {
+	Create(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+	List(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+	Revoke(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*ApiKeysServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Create", Handler: structmsg.UnaryHandler(CreateFullMethodName, ApiKeysServer.Create)},
+		{MethodName: "List", Handler: structmsg.UnaryHandler(ListFullMethodName, ApiKeysServer.List)},
+		{MethodName: "Revoke", Handler: structmsg.UnaryHandler(RevokeFullMethodName, ApiKeysServer.Revoke)},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: serviceMetadataFileName,
+}
+
+type ServerAPI struct {
+	apiKeys ApiKeys
+}
+
+// Register registers the api keys service on the gRPC server
+func Register(gRPC *grpc.Server, apiKeys ApiKeys) {
+	gRPC.RegisterService(&serviceDesc, &ServerAPI{apiKeys: apiKeys})
+}
+
+// Create issues new key of the calling user, the key is returned only once
+func (api *ServerAPI) Create(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	key, keyID, err := api.apiKeys.Create(ctx, structmsg.String(in, fieldName))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return structmsg.Response(map[string]any{
+		"id":  keyID,
+		"key": key,
+	})
+}
+
+// List returns keys of the calling user including revoked ones
+func (api *ServerAPI) List(ctx context.Context, _ *structpb.Struct) (*structpb.Struct, error) {
+	keys, err := api.apiKeys.List(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	items := make([]any, 0, len(keys))
+	for _, key := range keys {
+		item := map[string]any{
+			"id":         key.ID,
+			"name":       key.Name,
+			"prefix":     key.Prefix,
+			"created_at": key.CreatedAt.Format(time.RFC3339),
+		}
+		if key.RevokedAt != nil {
+			item["revoked_at"] = key.RevokedAt.Format(time.RFC3339)
+		}
+		items = append(items, item)
+	}
+
+	return structmsg.Response(map[string]any{"keys": items})
+}
+
+// Revoke revokes the key of the calling user
+func (api *ServerAPI) Revoke(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	keyID, err := structmsg.Int(in, fieldID)
+	if err != nil {
+		return nil, err
+	}
+	if keyID <= 0 {
+		return nil, grpcerr.InvalidArgument(fieldID, "is required")
+	}
+
+	if err = api.apiKeys.Revoke(ctx, int64(keyID)); err != nil {
+		return nil, statusError(err)
+	}
+
+	return structmsg.Response(map[string]any{"id": keyID})
+}
@@ -0,0 +1,100 @@
+package grpcerr
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Errors of all services of the server carry ErrorInfo of one domain, so
+// clients tell them apart by the reason alone
+const (
+	Domain = "urlsaver"
+
+	ReasonAliasExists      = "ALIAS_EXISTS"
+	ReasonAliasNotFound    = "ALIAS_NOT_FOUND"
+	ReasonAliasReserved    = "ALIAS_RESERVED"
+	ReasonAliasInvalid     = "ALIAS_INVALID"
+	ReasonAliasGeneration  = "ALIAS_GENERATION_FAILED"
+	ReasonUrlIsInvalid     = "URL_IS_INVALID"
+	ReasonInvalidPageToken = "INVALID_PAGE_TOKEN"
+	ReasonInvalidArgument  = "INVALID_ARGUMENT"
+	ReasonUnauthenticated  = "UNAUTHENTICATED"
+	ReasonShortenerTimeout = "SHORTENER_TIMEOUT"
+	ReasonShortenerFailed  = "SHORTENER_UNAVAILABLE"
+	ReasonApiKeyNotFound   = "API_KEY_NOT_FOUND"
+	ReasonApiKeyForbidden  = "API_KEY_FORBIDDEN"
+	ReasonInternal         = "INTERNAL"
+
+	InternalMessage = "internal error"
+	canceledMessage = "request canceled"
+	deadlineMessage = "deadline exceeded"
+	retryDelay      = time.Second
+)
+
+// WithDetails builds status error which always carries ErrorInfo with the
+// given reason followed by the other details
+func WithDetails(code codes.Code, msg, reason string, details ...protoadapt.MessageV1) error {
+	st := status.New(code, msg)
+
+	details = append([]protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: reason, Domain: Domain}}, details...)
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+
+	return st.Err()
+}
+
+// Resource reports the sentinel error of the resource, e.g. of not found alias
+func Resource(code codes.Code, reason, resourceType string, sentinel error) error {
+	return WithDetails(code, sentinel.Error(), reason,
+		&errdetails.ResourceInfo{ResourceType: resourceType, Description: sentinel.Error()},
+	)
+}
+
+// Field reports the field of the request violating the rule of the sentinel error
+func Field(field, reason string, sentinel error) error {
+	return WithDetails(codes.InvalidArgument, sentinel.Error(), reason, BadRequest(field, sentinel.Error()))
+}
+
+// InvalidArgument reports the field of the request with the description of
+// its violation, e.g. "is required"
+func InvalidArgument(field, description string) error {
+	return WithDetails(codes.InvalidArgument, field+" "+description, ReasonInvalidArgument,
+		BadRequest(field, description),
+	)
+}
+
+// Internal hides the error from clients, it must be logged by the caller
+func Internal() error {
+	return WithDetails(codes.Internal, InternalMessage, ReasonInternal)
+}
+
+// Context translates cancellation and deadline of the request context
+func Context(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, deadlineMessage)
+	}
+
+	return status.Error(codes.Canceled, canceledMessage)
+}
+
+// BadRequest describes the violation of one field of the request
+func BadRequest(field, description string) *errdetails.BadRequest {
+	return &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: field, Description: description},
+		},
+	}
+}
+
+// RetryInfo tells clients the error is retryable after a second
+func RetryInfo() *errdetails.RetryInfo {
+	return &errdetails.RetryInfo{RetryDelay: durationpb.New(retryDelay)}
+}
@@ -0,0 +1,67 @@
+package structmsg
+
+import (
+	"context"
+	"math"
+
+	"github.com/nhassl3/url-saver/internals/grpc/grpcerr"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Services missing in the contract take and return google.protobuf.Struct,
+// these helpers read their requests and build their responses
+
+// UnaryHandler makes handler of the method of hand-written service
+// description, S is the server interface of the service
+func UnaryHandler[S any](
+	fullMethod string,
+	call func(srv S, ctx context.Context, in *structpb.Struct) (*structpb.Struct, error),
+) func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		in := new(structpb.Struct)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(S), ctx, in)
+		}
+
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}
+		handler := func(ctx context.Context, req any) (any, error) {
+			return call(srv.(S), ctx, req.(*structpb.Struct))
+		}
+
+		return interceptor(ctx, in, info, handler)
+	}
+}
+
+// String reads string field, omitted field is empty
+func String(in *structpb.Struct, name string) string {
+	return in.GetFields()[name].GetStringValue()
+}
+
+// Int reads whole number, omitted field is zero
+func Int(in *structpb.Struct, name string) (int, error) {
+	value, ok := in.GetFields()[name]
+	if !ok {
+		return 0, nil
+	}
+
+	number, ok := value.GetKind().(*structpb.Value_NumberValue)
+	if !ok || number.NumberValue != math.Trunc(number.NumberValue) || math.Abs(number.NumberValue) > math.MaxInt32 {
+		return 0, grpcerr.InvalidArgument(name, "must be a whole number")
+	}
+
+	return int(number.NumberValue), nil
+}
+
+// Response builds the response from the fields
+func Response(fields map[string]any) (*structpb.Struct, error) {
+	out, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, grpcerr.Internal()
+	}
+
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package urlsaver
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+	"github.com/nhassl3/url-saver/internals/grpc/grpcerr"
+	"github.com/nhassl3/url-saver/internals/storage"
+	"google.golang.org/grpc/codes"
+)
+
+const shortenerTimeoutMessage = "url shortener timed out"
+
+// statusError translates an error of the domain layer into gRPC status
+// with error details, so clients can tell retryable errors from fatal ones.
+// Messages are fixed per error, the chain of the error with names of
+// internal operations and upstream responses is left for logs
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, urlsaver.ErrAliasExists), errors.Is(err, storage.ErrAliasExists):
+		return grpcerr.Resource(codes.AlreadyExists, grpcerr.ReasonAliasExists, "alias", urlsaver.ErrAliasExists)
+	case errors.Is(err, urlsaver.ErrAliasNotFound), errors.Is(err, storage.ErrAliasNotFound):
+		return grpcerr.Resource(codes.NotFound, grpcerr.ReasonAliasNotFound, "alias", urlsaver.ErrAliasNotFound)
+	case errors.Is(err, urlsaver.ErrAliasReserved):
+		return grpcerr.Field("alias", grpcerr.ReasonAliasReserved, urlsaver.ErrAliasReserved)
+	case errors.Is(err, urlsaver.ErrAliasInvalid):
+		return grpcerr.Field("alias", grpcerr.ReasonAliasInvalid, urlsaver.ErrAliasInvalid)
+	case errors.Is(err, urlsaver.ErrShortener):
+		return shortenerError(err)
+	case errors.Is(err, urlsaver.ErrAliasGeneration):
+		return grpcerr.WithDetails(codes.Aborted, urlsaver.ErrAliasGeneration.Error(), grpcerr.ReasonAliasGeneration,
+			grpcerr.RetryInfo(),
+		)
+	case errors.Is(err, urlsaver.ErrInvalidExpiration):
+		return grpcerr.Field(MetaUrlExpiresAt, grpcerr.ReasonInvalidArgument, urlsaver.ErrInvalidExpiration)
+	case errors.Is(err, storage.ErrUrlIsInvalid):
+		return grpcerr.Field("url", grpcerr.ReasonUrlIsInvalid, storage.ErrUrlIsInvalid)
+	case errors.Is(err, urlsaver.ErrInvalidPageToken):
+		return grpcerr.Field("page_token", grpcerr.ReasonInvalidPageToken, urlsaver.ErrInvalidPageToken)
+	case errors.Is(err, urlsaver.ErrUnauthenticated):
+		return grpcerr.WithDetails(codes.Unauthenticated, urlsaver.ErrUnauthenticated.Error(), grpcerr.ReasonUnauthenticated)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return grpcerr.Context(err)
+	default:
+		return grpcerr.Internal()
+	}
+}
+
+// shortenerError translates an error of the url shortener client. Timeouts
+// become DeadlineExceeded, any other failure is Unavailable; both are retryable
+func shortenerError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return grpcerr.WithDetails(codes.DeadlineExceeded, shortenerTimeoutMessage, grpcerr.ReasonShortenerTimeout,
+			grpcerr.RetryInfo(),
+		)
+	}
+	if errors.Is(err, context.Canceled) {
+		return grpcerr.Context(err)
+	}
+
+	return grpcerr.WithDetails(codes.Unavailable, urlsaver.ErrShortener.Error(), grpcerr.ReasonShortenerFailed,
+		grpcerr.RetryInfo(),
+	)
+}
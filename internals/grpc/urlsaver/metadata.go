@@ -0,0 +1,185 @@
+package urlsaver
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/grpc/grpcerr"
+	"google.golang.org/grpc/metadata"
+)
+
+// Filter of List and searchable meta of Save and Update are passed as request
+// metadata, the messages of the contract have no fields for them. The keys
+// are listed in api/README.md
+const (
+	MetaFilterDomain      = "x-filter-domain"
+	MetaFilterAliasPrefix = "x-filter-alias-prefix"
+	MetaFilterTag         = "x-filter-tag"
+	MetaFilterQuery       = "x-filter-query"
+	MetaFilterCreatedFrom = "x-filter-created-from"
+	MetaFilterCreatedTo   = "x-filter-created-to"
+	MetaFilterUpdatedFrom = "x-filter-updated-from"
+	MetaFilterUpdatedTo   = "x-filter-updated-to"
+	MetaSortBy            = "x-sort-by"
+	MetaSortOrder         = "x-sort-order"
+
+	MetaUrlTitle = "x-url-title"
+	// MetaUrlTags is comma separated list of tags, empty value removes all tags
+	MetaUrlTags = "x-url-tags"
+	// MetaUrlExpiresAt is RFC 3339 time the url expires at, empty value
+	// makes it permanent. MetaUrlTTL is the same as duration from now, e.g. 72h
+	MetaUrlExpiresAt = "x-url-expires-at"
+	MetaUrlTTL       = "x-url-ttl"
+	// MetaDedupe is true or false and overrides the default dedupe mode of
+	// Save, saves with an alias are never deduplicated
+	MetaDedupe = "x-dedupe"
+
+	maxTitleLen = 500
+	maxTags     = 20
+	maxTagLen   = 50
+)
+
+// filterFromMetadata reads filter of the urls list from the request metadata
+func filterFromMetadata(ctx context.Context) (filter entities.UrlFilter, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	filter.Domain = metaValue(md, MetaFilterDomain)
+	filter.AliasPrefix = metaValue(md, MetaFilterAliasPrefix)
+	filter.Tag = strings.ToLower(metaValue(md, MetaFilterTag))
+	filter.Query = metaValue(md, MetaFilterQuery)
+
+	for key, dst := range map[string]*time.Time{
+		MetaFilterCreatedFrom: &filter.CreatedFrom,
+		MetaFilterCreatedTo:   &filter.CreatedTo,
+		MetaFilterUpdatedFrom: &filter.UpdatedFrom,
+		MetaFilterUpdatedTo:   &filter.UpdatedTo,
+	} {
+		value := metaValue(md, key)
+		if value == "" {
+			continue
+		}
+		if *dst, err = time.Parse(time.RFC3339, value); err != nil {
+			return entities.UrlFilter{}, grpcerr.InvalidArgument(key, "must be a RFC 3339 time")
+		}
+	}
+
+	switch sortBy := entities.SortField(metaValue(md, MetaSortBy)); sortBy {
+	case "", entities.SortByCreatedAt, entities.SortByUpdatedAt, entities.SortByAlias:
+		filter.SortBy = sortBy
+	default:
+		return entities.UrlFilter{}, grpcerr.InvalidArgument(MetaSortBy, "must be one of created_at, updated_at, alias")
+	}
+
+	switch metaValue(md, MetaSortOrder) {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return entities.UrlFilter{}, grpcerr.InvalidArgument(MetaSortOrder, "must be asc or desc")
+	}
+
+	return filter, nil
+}
+
+// metaFromMetadata reads title and tags of the url from the request metadata.
+// Tags are nil when the key is absent, so update keeps the current ones
+func metaFromMetadata(ctx context.Context) (meta entities.UrlMeta, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	meta.Title = metaValue(md, MetaUrlTitle)
+	if len(meta.Title) > maxTitleLen {
+		return entities.UrlMeta{}, grpcerr.InvalidArgument(MetaUrlTitle, "must be at most 500 characters")
+	}
+
+	if meta.ExpiresAt, err = expirationFromMetadata(md); err != nil {
+		return entities.UrlMeta{}, err
+	}
+
+	values := md.Get(MetaUrlTags)
+	if len(values) == 0 {
+		return meta, nil
+	}
+
+	meta.Tags = []string{}
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" || seen[tag] {
+				continue
+			}
+			if len(tag) > maxTagLen {
+				return entities.UrlMeta{}, grpcerr.InvalidArgument(MetaUrlTags, "every tag must be at most 50 characters")
+			}
+			seen[tag] = true
+			meta.Tags = append(meta.Tags, tag)
+		}
+	}
+	if len(meta.Tags) > maxTags {
+		return entities.UrlMeta{}, grpcerr.InvalidArgument(MetaUrlTags, "must be at most 20 tags")
+	}
+
+	return meta, nil
+}
+
+// expirationFromMetadata returns nil when neither expiration time nor TTL
+// is set and zero time when the url must be made permanent
+func expirationFromMetadata(md metadata.MD) (*time.Time, error) {
+	_, hasExpiresAt := md[MetaUrlExpiresAt]
+	_, hasTTL := md[MetaUrlTTL]
+
+	switch {
+	case hasExpiresAt && hasTTL:
+		return nil, grpcerr.InvalidArgument(MetaUrlTTL, "must not be set together with "+MetaUrlExpiresAt)
+	case hasExpiresAt:
+		value := metaValue(md, MetaUrlExpiresAt)
+		if value == "" {
+			return &time.Time{}, nil
+		}
+
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, grpcerr.InvalidArgument(MetaUrlExpiresAt, "must be a RFC 3339 time")
+		}
+		return &expiresAt, nil
+	case hasTTL:
+		ttl, err := time.ParseDuration(metaValue(md, MetaUrlTTL))
+		if err != nil || ttl <= 0 {
+			return nil, grpcerr.InvalidArgument(MetaUrlTTL, "must be a positive duration, e.g. 72h")
+		}
+
+		expiresAt := time.Now().Add(ttl)
+		return &expiresAt, nil
+	default:
+		return nil, nil
+	}
+}
+
+// dedupeFromMetadata reads dedupe override of Save, nil when it is not set
+func dedupeFromMetadata(ctx context.Context) (*bool, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	value := metaValue(md, MetaDedupe)
+	if value == "" {
+		return nil, nil
+	}
+
+	dedupe, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, grpcerr.InvalidArgument(MetaDedupe, "must be true or false")
+	}
+
+	return &dedupe, nil
+}
+
+func metaValue(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(values[0])
+}
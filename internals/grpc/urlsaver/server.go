@@ -2,9 +2,10 @@ package urlsaver
 
 import (
 	"context"
+	"errors"
 
 	urlsv1 "github.com/nhassl3/url-saver-contracts/generated/go/urlsaver"
-	urlshortener "github.com/nhassl3/url-saver/internals/clients/urlshortener/http"
+	"github.com/nhassl3/url-saver/internals/domain/entities"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -16,37 +17,75 @@ const (
 )
 
 type UrlSaver interface {
-	Save(ctx context.Context, url, aliasReq string) (urlID int64, aliasRes string, err error)
+	Save(
+		ctx context.Context,
+		url, aliasReq string,
+		meta entities.UrlMeta,
+		dedupe *bool,
+	) (urlID int64, aliasRes string, err error)
 	Get(ctx context.Context, aliasReq string) (url, aliasRes string, urlID int64, err error)
-	UpdateByID(ctx context.Context, urlID int64, newURL, newAliasReq string) (success bool, newAliasRes string, err error)
-	UpdateByAlias(ctx context.Context, alias, newURL, newAliasReq string) (success bool, newAliasRes string, err error)
+	UpdateByID(
+		ctx context.Context,
+		urlID int64,
+		newURL, newAliasReq string,
+		meta entities.UrlMeta,
+	) (success bool, newAliasRes string, err error)
+	UpdateByAlias(
+		ctx context.Context,
+		alias, newURL, newAliasReq string,
+		meta entities.UrlMeta,
+	) (success bool, newAliasRes string, err error)
 	RemoveByID(ctx context.Context, urlID int64) (success bool, removedUrlID int64, err error)
 	RemoveByAlias(ctx context.Context, aliasReq string) (success bool, removedUrlID int64, err error)
-	List(ctx context.Context, pageToken string, pageSize int32) (URLs []*urlsv1.UrlItem, nextPageToken string, err error)
+	RestoreByID(ctx context.Context, urlID int64) (url, alias string, restoredUrlID int64, err error)
+	RestoreByAlias(ctx context.Context, aliasReq string) (url, alias string, restoredUrlID int64, err error)
+	List(
+		ctx context.Context,
+		filter entities.UrlFilter,
+		pageToken string,
+		pageSize int32,
+	) (URLs []*urlsv1.UrlItem, nextPageToken string, err error)
 }
 
 type ServerAPI struct {
 	urlsv1.UnimplementedUrlSaverServer
-	urlSaver     UrlSaver
-	urlShortener *urlshortener.Client
+	urlSaver UrlSaver
 }
 
 // Register registration server through generated code from protobuf and use function
 // registers UrlSaver server, but it's not only register this service
 // and other services clients too
-func Register(gRPC *grpc.Server, urlSaver UrlSaver, urlShortenerClient *urlshortener.Client) {
-	urlsv1.RegisterUrlSaverServer(gRPC, &ServerAPI{urlSaver: urlSaver, urlShortener: urlShortenerClient})
+func Register(gRPC *grpc.Server, urlSaver UrlSaver) {
+	api := NewServerAPI(urlSaver)
+
+	urlsv1.RegisterUrlSaverServer(gRPC, api)
+	gRPC.RegisterService(&trashServiceDesc, api)
+}
+
+// NewServerAPI creates UrlSaver server without registering it,
+// so other transports can serve the same API in-process
+func NewServerAPI(urlSaver UrlSaver) *ServerAPI {
+	return &ServerAPI{urlSaver: urlSaver}
 }
 
 func (api *ServerAPI) Save(ctx context.Context, in *urlsv1.SaveRequest) (*urlsv1.SaveResponse, error) {
-	if err := in.Validate(); err != nil {
+	if err := validateSave(in); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	urlID, aliasRes, err := api.urlSaver.Save(ctx, in.GetUrl(), in.GetAlias())
+	meta, err := metaFromMetadata(ctx)
 	if err != nil {
-		// TODO: implement not Internal error through condition error with errors.Is()
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
+	}
+
+	dedupe, err := dedupeFromMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	urlID, aliasRes, err := api.urlSaver.Save(ctx, in.GetUrl(), in.GetAlias(), meta, dedupe)
+	if err != nil {
+		return nil, statusError(err)
 	}
 
 	return &urlsv1.SaveResponse{
@@ -62,8 +101,7 @@ func (api *ServerAPI) Get(ctx context.Context, in *urlsv1.GetRequest) (*urlsv1.G
 
 	url, aliasRes, urlID, err := api.urlSaver.Get(ctx, in.GetAlias())
 	if err != nil {
-		// TODO: implement not Internal error through condition error with errors.Is()
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, statusError(err)
 	}
 
 	return &urlsv1.GetResponse{
@@ -75,37 +113,25 @@ func (api *ServerAPI) Get(ctx context.Context, in *urlsv1.GetRequest) (*urlsv1.G
 
 func (api *ServerAPI) Update(ctx context.Context, in *urlsv1.UpdateRequest) (*urlsv1.UpdateResponse, error) {
 	var (
-		success              bool
-		newAliasRes          string
-		err                  error
-		UrlShortenerResponse *urlshortener.ShortenResponse
+		success     bool
+		newAliasRes string
+		err         error
 	)
 
 	if err := in.Validate(); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	meta, err := metaFromMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	switch v := in.GetIdentifier().(type) {
 	case *urlsv1.UpdateRequest_UrlId:
-		UrlShortenerResponse, err = api.urlShortener.ShortenURL(ctx, in.GetNewUrl(), in.GetNewAlias())
-		if err != nil {
-			// TODO: none Internal error too
-			return nil, status.Error(codes.Internal, err.Error())
-		}
-
-		success, newAliasRes, err = api.urlSaver.UpdateByID(
-			ctx, v.UrlId, UrlShortenerResponse.GetURL(), UrlShortenerResponse.GetAlias(),
-		)
+		success, newAliasRes, err = api.urlSaver.UpdateByID(ctx, v.UrlId, in.GetNewUrl(), in.GetNewAlias(), meta)
 	case *urlsv1.UpdateRequest_Alias:
-		UrlShortenerResponse, err = api.urlShortener.ShortenURL(ctx, in.GetNewUrl(), in.GetNewAlias())
-		if err != nil {
-			// TODO: none Internal error too
-			return nil, status.Error(codes.Internal, err.Error())
-		}
-
-		success, newAliasRes, err = api.urlSaver.UpdateByAlias(
-			ctx, v.Alias, UrlShortenerResponse.GetURL(), UrlShortenerResponse.GetAlias(),
-		)
+		success, newAliasRes, err = api.urlSaver.UpdateByAlias(ctx, v.Alias, in.GetNewUrl(), in.GetNewAlias(), meta)
 	case nil:
 		return nil, status.Error(codes.InvalidArgument, NoIdentifier)
 	default:
@@ -113,8 +139,7 @@ func (api *ServerAPI) Update(ctx context.Context, in *urlsv1.UpdateRequest) (*ur
 	}
 
 	if err != nil {
-		// TODO: implement not Internal error through condition error with errors.Is()
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, statusError(err)
 	}
 
 	return &urlsv1.UpdateResponse{
@@ -146,8 +171,7 @@ func (api *ServerAPI) Remove(ctx context.Context, in *urlsv1.RemoveRequest) (*ur
 	}
 
 	if err != nil {
-		// TODO: implement not Internal error through condition error with errors.Is()
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, statusError(err)
 	}
 
 	return &urlsv1.RemoveResponse{
@@ -161,10 +185,14 @@ func (api *ServerAPI) List(ctx context.Context, in *urlsv1.ListRequest) (*urlsv1
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	URLs, nextPageToken, err := api.urlSaver.List(ctx, in.GetPageToken(), in.GetPageSize())
+	filter, err := filterFromMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	URLs, nextPageToken, err := api.urlSaver.List(ctx, filter, in.GetPageToken(), in.GetPageSize())
 	if err != nil {
-		// TODO: implement not Internal error through condition error with errors.Is()
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, statusError(err)
 	}
 
 	return &urlsv1.ListResponse{
@@ -172,3 +200,31 @@ func (api *ServerAPI) List(ctx context.Context, in *urlsv1.ListRequest) (*urlsv1
 		NextPageToken: nextPageToken,
 	}, nil
 }
+
+// validateSave validates the request like Validate does, except empty alias
+// is allowed: the alias is generated then
+func validateSave(in *urlsv1.SaveRequest) error {
+	err := in.ValidateAll()
+	if err == nil || in.GetAlias() != "" {
+		return err
+	}
+
+	var multiErr urlsv1.SaveRequestMultiError
+	if !errors.As(err, &multiErr) {
+		return err
+	}
+
+	var violations urlsv1.SaveRequestMultiError
+	for _, violation := range multiErr.AllErrors() {
+		var fieldErr urlsv1.SaveRequestValidationError
+		if errors.As(violation, &fieldErr) && fieldErr.Field() == "Alias" {
+			continue
+		}
+		violations = append(violations, violation)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return violations
+}
@@ -0,0 +1,90 @@
+package urlsaver
+
+import (
+	"context"
+
+	urlsv1 "github.com/nhassl3/url-saver-contracts/generated/go/urlsaver"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The trash service is not a part of the contract yet. It is described by
+// hand with messages of the contract, so clients call it by the full method
+// name: RemoveRequest identifies the url to restore, GetResponse returns it.
+// Its contract is api/proto/urlsaver/url_saver_trash.proto
+const (
+	TrashServiceName             = "UrlSaver.UrlSaverTrash"
+	TrashRestoreFullMethodName   = "/" + TrashServiceName + "/Restore"
+	trashServiceMetadataFileName = "url_saver_trash"
+)
+
+type TrashServer interface {
+	Restore(ctx context.Context, in *urlsv1.RemoveRequest) (*urlsv1.GetResponse, error)
+}
+
+var trashServiceDesc = grpc.ServiceDesc{
+	ServiceName: TrashServiceName,
+	HandlerType: (*TrashServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Restore", Handler: trashRestoreHandler},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: trashServiceMetadataFileName,
+}
+
+func trashRestoreHandler(
+	srv any,
+	ctx context.Context,
+	dec func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	in := new(urlsv1.RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrashServer).Restore(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: TrashRestoreFullMethodName}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(TrashServer).Restore(ctx, req.(*urlsv1.RemoveRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+// Restore takes removed url out of the trash by its ID or alias
+func (api *ServerAPI) Restore(ctx context.Context, in *urlsv1.RemoveRequest) (*urlsv1.GetResponse, error) {
+	var (
+		url, alias string
+		urlID      int64
+		err        error
+	)
+
+	if err := in.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	switch v := in.GetIdentifier().(type) {
+	case *urlsv1.RemoveRequest_UrlId:
+		url, alias, urlID, err = api.urlSaver.RestoreByID(ctx, v.UrlId)
+	case *urlsv1.RemoveRequest_Alias:
+		url, alias, urlID, err = api.urlSaver.RestoreByAlias(ctx, v.Alias)
+	case nil:
+		return nil, status.Error(codes.InvalidArgument, NoIdentifier)
+	default:
+		return nil, status.Error(codes.InvalidArgument, UnknownAliasOrID)
+	}
+
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &urlsv1.GetResponse{
+		Url:   url,
+		Alias: alias,
+		UrlId: urlID,
+	}, nil
+}
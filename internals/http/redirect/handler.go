@@ -0,0 +1,121 @@
+package redirect
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+)
+
+var notFoundPage = template.Must(template.New("not_found").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>Link not found</title>
+</head>
+<body>
+    <h1>404</h1>
+    <p>There is no link with alias <b>{{ . }}</b>.</p>
+</body>
+</html>
+`))
+
+type UrlGetter interface {
+	Get(ctx context.Context, aliasReq string) (url, aliasRes string, urlID int64, err error)
+}
+
+type VisitRecorder interface {
+	Record(urlID int64, alias, referrer, userAgent, ip string)
+}
+
+// Handler redirects requests for aliases to the saved urls
+type Handler struct {
+	log           *slog.Logger
+	urlGetter     UrlGetter
+	visitRecorder VisitRecorder
+	statusCode    int
+}
+
+// Register registers redirect routes. When aliases are unique only within
+// urls of one user, the alias must be prefixed with ID of its owner
+func Register(
+	mux *http.ServeMux,
+	log *slog.Logger,
+	urlGetter UrlGetter,
+	visitRecorder VisitRecorder,
+	statusCode int,
+	perUserAliases bool,
+) {
+	h := &Handler{
+		log:           log,
+		urlGetter:     urlGetter,
+		visitRecorder: visitRecorder,
+		statusCode:    statusCode,
+	}
+
+	if perUserAliases {
+		mux.HandleFunc("GET /{user_id}/{alias}", h.RedirectUser)
+	} else {
+		mux.HandleFunc("GET /{alias}", h.Redirect)
+	}
+}
+
+func (h *Handler) Redirect(w http.ResponseWriter, r *http.Request) {
+	h.redirect(w, r, r.Context(), r.PathValue("alias"))
+}
+
+func (h *Handler) RedirectUser(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		h.notFound(w, alias)
+		return
+	}
+
+	h.redirect(w, r, userctx.WithUserID(r.Context(), userID), alias)
+}
+
+func (h *Handler) redirect(w http.ResponseWriter, r *http.Request, ctx context.Context, alias string) {
+	url, aliasRes, urlID, err := h.urlGetter.Get(ctx, alias)
+	if err != nil {
+		if errors.Is(err, urlsaver.ErrAliasNotFound) {
+			h.notFound(w, alias)
+			return
+		}
+		h.log.Error("failed to resolve alias", slog.String("alias", alias), sl.Err(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	h.visitRecorder.Record(urlID, aliasRes, r.Referer(), r.UserAgent(), clientIP(r))
+
+	http.Redirect(w, r, url, h.statusCode)
+}
+
+// clientIP returns address of the connected client, proxies in front of
+// the server are not trusted
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func (h *Handler) notFound(w http.ResponseWriter, alias string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+
+	if err := notFoundPage.Execute(w, alias); err != nil {
+		h.log.Error("failed to render not found page", sl.Err(err))
+	}
+}
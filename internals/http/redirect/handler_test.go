@@ -0,0 +1,164 @@
+package redirect_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	localshortener "github.com/nhassl3/url-saver/internals/clients/urlshortener/local"
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/domain/services/urlnorm"
+	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+	"github.com/nhassl3/url-saver/internals/http/redirect"
+	"github.com/nhassl3/url-saver/internals/lib/aliasgen"
+	"github.com/nhassl3/url-saver/internals/lib/pagetoken"
+	"github.com/nhassl3/url-saver/internals/storage/memory"
+)
+
+const userID = 1
+
+type visit struct {
+	urlID int64
+	alias string
+}
+
+type visitRecorder struct {
+	visits []visit
+}
+
+func (r *visitRecorder) Record(urlID int64, alias, _, _, _ string) {
+	r.visits = append(r.visits, visit{urlID: urlID, alias: alias})
+}
+
+// newServer registers the redirect routes over memory storage holding
+// a live, an expired and a deleted url of userID, aliased the same way.
+// Found redirects are answered with statusCode
+func newServer(t *testing.T, perUserAliases bool, statusCode int) (*http.ServeMux, *visitRecorder, int64) {
+	t.Helper()
+
+	log := slog.New(slog.DiscardHandler)
+	ctx := context.Background()
+
+	aliases, err := aliasgen.NewRandom("abcdefghijklmnopqrstuvwxyz0123456789", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := memory.NewStorage(!perUserAliases)
+	saver := urlsaver.NewUrlSaver(
+		log, s, s, s, localshortener.NewShortener(log, aliases),
+		urlnorm.NewNormalizer([]string{"http", "https"}, 2048, false),
+		pagetoken.NewCodec([]byte("secret")), aliases,
+		urlsaver.Options{MaxPageSize: 100, AliasRetries: 3},
+	)
+
+	liveID, err := s.SaveUrl(ctx, userID, "https://example.com/live", "live", entities.UrlMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Minute)
+	if _, err = s.SaveUrl(ctx, userID, "https://example.com/expired", "expired", entities.UrlMeta{ExpiresAt: &past}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.SaveUrl(ctx, userID, "https://example.com/deleted", "deleted", entities.UrlMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.RemoveUrl(ctx, userID, "deleted"); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &visitRecorder{}
+	mux := http.NewServeMux()
+	redirect.Register(mux, log, saver, recorder, statusCode, perUserAliases)
+
+	return mux, recorder, liveID
+}
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		name           string
+		perUserAliases bool
+		path           string
+		wantCode       int
+		wantLocation   string
+	}{
+		{name: "live", path: "/live", wantCode: http.StatusFound, wantLocation: "https://example.com/live"},
+		{name: "unknown", path: "/unknown", wantCode: http.StatusNotFound},
+		{name: "expired", path: "/expired", wantCode: http.StatusNotFound},
+		{name: "deleted", path: "/deleted", wantCode: http.StatusNotFound},
+		{name: "user prefix in global mode", path: "/1/live", wantCode: http.StatusNotFound},
+
+		{
+			name: "per user live", perUserAliases: true, path: "/1/live",
+			wantCode: http.StatusFound, wantLocation: "https://example.com/live",
+		},
+		{name: "per user unknown", perUserAliases: true, path: "/1/unknown", wantCode: http.StatusNotFound},
+		{name: "per user expired", perUserAliases: true, path: "/1/expired", wantCode: http.StatusNotFound},
+		{name: "per user deleted", perUserAliases: true, path: "/1/deleted", wantCode: http.StatusNotFound},
+		{name: "per user other user", perUserAliases: true, path: "/2/live", wantCode: http.StatusNotFound},
+		{name: "per user bad user id", perUserAliases: true, path: "/one/live", wantCode: http.StatusNotFound},
+		{name: "per user zero user id", perUserAliases: true, path: "/0/live", wantCode: http.StatusNotFound},
+		{name: "per user without user id", perUserAliases: true, path: "/live", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux, recorder, liveID := newServer(t, tt.perUserAliases, http.StatusFound)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+
+			if tt.wantCode != http.StatusFound {
+				if len(recorder.visits) != 0 {
+					t.Errorf("visits = %v, want none", recorder.visits)
+				}
+				return
+			}
+			if len(recorder.visits) != 1 || recorder.visits[0] != (visit{urlID: liveID, alias: "live"}) {
+				t.Errorf("visits = %v, want one of url %d", recorder.visits, liveID)
+			}
+		})
+	}
+}
+
+func TestRedirectNotFoundPage(t *testing.T) {
+	mux, _, _ := newServer(t, false, http.StatusFound)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/%3Cb%3E", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", got)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "&lt;b&gt;") || strings.Contains(body, "<b><b>") {
+		t.Errorf("alias is not escaped in the page: %s", body)
+	}
+}
+
+func TestRedirectStatusCode(t *testing.T) {
+	for _, code := range []int{http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect} {
+		t.Run(http.StatusText(code), func(t *testing.T) {
+			mux, _, _ := newServer(t, false, code)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/live", nil))
+
+			if rec.Code != code {
+				t.Errorf("status = %d, want %d", rec.Code, code)
+			}
+		})
+	}
+}
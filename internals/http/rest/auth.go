@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/nhassl3/url-saver/internals/domain/services/apikeys"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	apiKeyHeader = "X-Api-Key"
+	bearerScheme = "bearer"
+
+	errNoCredentials = "bearer token or api key is not provided"
+	errInvalidToken  = "bearer token is invalid"
+	errInvalidApiKey = "api key is invalid"
+	errAuthFailed    = "failed to authenticate"
+)
+
+type TokenVerifier interface {
+	Verify(token string) (userID int64, err error)
+}
+
+type ApiKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (userID int64, err error)
+}
+
+// AuthMiddleware authenticates requests by X-Api-Key header or by bearer
+// token from Authorization header, the same way the gRPC server does
+type AuthMiddleware struct {
+	log           *slog.Logger
+	verifier      TokenVerifier
+	authenticator ApiKeyAuthenticator
+}
+
+func NewAuthMiddleware(log *slog.Logger, verifier TokenVerifier, authenticator ApiKeyAuthenticator) *AuthMiddleware {
+	return &AuthMiddleware{
+		log:           log,
+		verifier:      verifier,
+		authenticator: authenticator,
+	}
+}
+
+func (m *AuthMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get(apiKeyHeader); key != "" {
+			userID, err := m.authenticator.Authenticate(r.Context(), key)
+			if err != nil {
+				// storage failures are not the fault of the key, clients must not drop it
+				if !errors.Is(err, apikeys.ErrInvalidApiKey) {
+					m.log.Error("failed to authenticate api key", slog.String("path", r.URL.Path), sl.Err(err))
+					writeError(m.log, w, status.Error(codes.Internal, errAuthFailed))
+					return
+				}
+
+				m.log.Debug("request rejected", slog.String("path", r.URL.Path), sl.Err(err))
+				writeError(m.log, w, status.Error(codes.Unauthenticated, errInvalidApiKey))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(userctx.WithApiKey(userctx.WithUserID(r.Context(), userID))))
+			return
+		}
+
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, bearerScheme) || strings.TrimSpace(token) == "" {
+			writeError(m.log, w, status.Error(codes.Unauthenticated, errNoCredentials))
+			return
+		}
+
+		userID, err := m.verifier.Verify(strings.TrimSpace(token))
+		if err != nil {
+			m.log.Debug("request rejected", slog.String("path", r.URL.Path), sl.Err(err))
+			writeError(m.log, w, status.Error(codes.Unauthenticated, errInvalidToken))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(userctx.WithUserID(r.Context(), userID)))
+	})
+}
@@ -0,0 +1,276 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	urlsv1 "github.com/nhassl3/url-saver-contracts/generated/go/urlsaver"
+	grpcurlsaver "github.com/nhassl3/url-saver/internals/grpc/urlsaver"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	maxBodySize = 1 << 20
+
+	errBadBody     = "request body is not a valid JSON object"
+	errBadPageSize = "page_size must be an integer"
+)
+
+// listFilterParams maps query parameters of the list onto request metadata
+// the gRPC API reads the filter from
+var listFilterParams = map[string]string{
+	"domain":       grpcurlsaver.MetaFilterDomain,
+	"alias_prefix": grpcurlsaver.MetaFilterAliasPrefix,
+	"tag":          grpcurlsaver.MetaFilterTag,
+	"q":            grpcurlsaver.MetaFilterQuery,
+	"created_from": grpcurlsaver.MetaFilterCreatedFrom,
+	"created_to":   grpcurlsaver.MetaFilterCreatedTo,
+	"updated_from": grpcurlsaver.MetaFilterUpdatedFrom,
+	"updated_to":   grpcurlsaver.MetaFilterUpdatedTo,
+	"sort_by":      grpcurlsaver.MetaSortBy,
+	"sort_order":   grpcurlsaver.MetaSortOrder,
+}
+
+// urlMetaBody is the part of the request body which is not in the messages
+// of the contract, it is passed to the gRPC API as metadata
+type urlMetaBody struct {
+	Title     *string  `json:"title"`
+	Tags      []string `json:"tags"`
+	ExpiresAt *string  `json:"expires_at"`
+	TTL       *string  `json:"ttl"`
+	Dedupe    *bool    `json:"dedupe"`
+}
+
+var (
+	marshaler   = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+	unmarshaler = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// UrlSaverServer is the gRPC API of UrlSaver, the gateway calls it in-process
+// so requests are validated and errors are mapped the same way for both
+type UrlSaverServer interface {
+	Save(ctx context.Context, in *urlsv1.SaveRequest) (*urlsv1.SaveResponse, error)
+	Get(ctx context.Context, in *urlsv1.GetRequest) (*urlsv1.GetResponse, error)
+	Update(ctx context.Context, in *urlsv1.UpdateRequest) (*urlsv1.UpdateResponse, error)
+	Remove(ctx context.Context, in *urlsv1.RemoveRequest) (*urlsv1.RemoveResponse, error)
+	List(ctx context.Context, in *urlsv1.ListRequest) (*urlsv1.ListResponse, error)
+	Restore(ctx context.Context, in *urlsv1.RemoveRequest) (*urlsv1.GetResponse, error)
+}
+
+// Handler is REST/JSON gateway of the UrlSaver API
+type Handler struct {
+	log    *slog.Logger
+	server UrlSaverServer
+}
+
+// Register registers routes of the gateway under /api/v1/. Every route
+// requires authentication by bearer token or api key
+func Register(
+	mux *http.ServeMux,
+	log *slog.Logger,
+	server UrlSaverServer,
+	tokenVerifier TokenVerifier,
+	apiKeyAuthenticator ApiKeyAuthenticator,
+) {
+	h := &Handler{
+		log:    log,
+		server: server,
+	}
+	auth := NewAuthMiddleware(log, tokenVerifier, apiKeyAuthenticator)
+
+	mux.Handle("POST /api/v1/urls", auth.Wrap(http.HandlerFunc(h.Save)))
+	mux.Handle("GET /api/v1/urls", auth.Wrap(http.HandlerFunc(h.List)))
+	mux.Handle("GET /api/v1/urls/{alias}", auth.Wrap(http.HandlerFunc(h.Get)))
+	mux.Handle("PATCH /api/v1/urls/{alias}", auth.Wrap(http.HandlerFunc(h.Update)))
+	mux.Handle("DELETE /api/v1/urls/{alias}", auth.Wrap(http.HandlerFunc(h.Remove)))
+	mux.Handle("POST /api/v1/urls/{alias}/restore", auth.Wrap(http.HandlerFunc(h.Restore)))
+}
+
+func (h *Handler) Save(w http.ResponseWriter, r *http.Request) {
+	in := &urlsv1.SaveRequest{}
+	ctx, ok := h.readBody(w, r, in)
+	if !ok {
+		return
+	}
+
+	out, err := h.server.Save(ctx, in)
+	h.writeResponse(w, http.StatusCreated, out, err)
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	out, err := h.server.Get(r.Context(), &urlsv1.GetRequest{Alias: r.PathValue("alias")})
+	h.writeResponse(w, http.StatusOK, out, err)
+}
+
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	in := &urlsv1.UpdateRequest{}
+	ctx, ok := h.readBody(w, r, in)
+	if !ok {
+		return
+	}
+	in.Identifier = &urlsv1.UpdateRequest_Alias{Alias: r.PathValue("alias")}
+
+	out, err := h.server.Update(ctx, in)
+	h.writeResponse(w, http.StatusOK, out, err)
+}
+
+func (h *Handler) Remove(w http.ResponseWriter, r *http.Request) {
+	out, err := h.server.Remove(r.Context(), &urlsv1.RemoveRequest{
+		Identifier: &urlsv1.RemoveRequest_Alias{Alias: r.PathValue("alias")},
+	})
+	h.writeResponse(w, http.StatusOK, out, err)
+}
+
+func (h *Handler) Restore(w http.ResponseWriter, r *http.Request) {
+	out, err := h.server.Restore(r.Context(), &urlsv1.RemoveRequest{
+		Identifier: &urlsv1.RemoveRequest_Alias{Alias: r.PathValue("alias")},
+	})
+	h.writeResponse(w, http.StatusOK, out, err)
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	in := &urlsv1.ListRequest{PageToken: query.Get("page_token")}
+	if pageSize := query.Get("page_size"); pageSize != "" {
+		size, err := strconv.ParseInt(pageSize, 10, 32)
+		if err != nil {
+			writeError(h.log, w, status.Error(codes.InvalidArgument, errBadPageSize))
+			return
+		}
+		in.PageSize = int32(size)
+	}
+
+	md := metadata.MD{}
+	for param, key := range listFilterParams {
+		if value := query.Get(param); value != "" {
+			md.Set(key, value)
+		}
+	}
+
+	out, err := h.server.List(withMetadata(r.Context(), md), in)
+	h.writeResponse(w, http.StatusOK, out, err)
+}
+
+// readBody decodes JSON body of the request into the message, fields out of
+// the contract are put into the returned context as metadata. On failure
+// the error is written to the response
+func (h *Handler) readBody(w http.ResponseWriter, r *http.Request, in proto.Message) (context.Context, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(h.log, w, status.Error(codes.InvalidArgument, errBadBody))
+		return nil, false
+	}
+
+	if err := unmarshaler.Unmarshal(body, in); err != nil {
+		writeError(h.log, w, status.Error(codes.InvalidArgument, errBadBody))
+		return nil, false
+	}
+
+	var meta urlMetaBody
+	if err := json.Unmarshal(body, &meta); err != nil {
+		writeError(h.log, w, status.Error(codes.InvalidArgument, errBadBody))
+		return nil, false
+	}
+
+	md := metadata.MD{}
+	if meta.Title != nil {
+		md.Set(grpcurlsaver.MetaUrlTitle, *meta.Title)
+	}
+	if meta.Tags != nil {
+		md.Set(grpcurlsaver.MetaUrlTags, strings.Join(meta.Tags, ","))
+	}
+	if meta.ExpiresAt != nil {
+		md.Set(grpcurlsaver.MetaUrlExpiresAt, *meta.ExpiresAt)
+	}
+	if meta.TTL != nil {
+		md.Set(grpcurlsaver.MetaUrlTTL, *meta.TTL)
+	}
+	if meta.Dedupe != nil {
+		md.Set(grpcurlsaver.MetaDedupe, strconv.FormatBool(*meta.Dedupe))
+	}
+
+	return withMetadata(r.Context(), md), true
+}
+
+// withMetadata adds md to the incoming metadata of the context, the same
+// way gRPC server passes headers of the request
+func withMetadata(ctx context.Context, md metadata.MD) context.Context {
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		md = metadata.Join(incoming, md)
+	}
+
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func (h *Handler) writeResponse(w http.ResponseWriter, code int, out proto.Message, err error) {
+	if err != nil {
+		writeError(h.log, w, err)
+		return
+	}
+
+	writeJSON(h.log, w, code, out)
+}
+
+// writeError writes gRPC status of the error with its details as JSON
+func writeError(log *slog.Logger, w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+
+	writeJSON(log, w, HTTPStatusFromCode(st.Code()), st.Proto())
+}
+
+func writeJSON(log *slog.Logger, w http.ResponseWriter, code int, msg proto.Message) {
+	body, err := marshaler.Marshal(msg)
+	if err != nil {
+		log.Error("failed to marshal response", sl.Err(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if _, err := w.Write(body); err != nil {
+		log.Debug("failed to write response", sl.Err(err))
+	}
+}
+
+// HTTPStatusFromCode returns HTTP status matching the gRPC code
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
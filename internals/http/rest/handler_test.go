@@ -0,0 +1,407 @@
+package rest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	urlsv1 "github.com/nhassl3/url-saver-contracts/generated/go/urlsaver"
+	"github.com/nhassl3/url-saver/internals/domain/services/apikeys"
+	"github.com/nhassl3/url-saver/internals/grpc/grpcerr"
+	grpcurlsaver "github.com/nhassl3/url-saver/internals/grpc/urlsaver"
+	"github.com/nhassl3/url-saver/internals/http/rest"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	validToken  = "valid-token"
+	validApiKey = "valid-api-key"
+	brokenKey   = "broken-api-key"
+	userID      = 1
+)
+
+type stubVerifier struct{}
+
+func (stubVerifier) Verify(token string) (int64, error) {
+	if token != validToken {
+		return 0, errors.New("invalid token")
+	}
+
+	return userID, nil
+}
+
+type stubAuthenticator struct{}
+
+func (stubAuthenticator) Authenticate(_ context.Context, key string) (int64, error) {
+	switch key {
+	case validApiKey:
+		return userID, nil
+	case brokenKey:
+		return 0, errors.New("storage is unavailable")
+	default:
+		return 0, apikeys.ErrInvalidApiKey
+	}
+}
+
+// stubServer answers every call with err, or with a fixed response when err
+// is nil. The context and the request of the last call are kept
+type stubServer struct {
+	err error
+	ctx context.Context
+	in  any
+}
+
+func (s *stubServer) Save(ctx context.Context, in *urlsv1.SaveRequest) (*urlsv1.SaveResponse, error) {
+	s.ctx, s.in = ctx, in
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &urlsv1.SaveResponse{UrlId: 1, Alias: "alias"}, nil
+}
+
+func (s *stubServer) Get(ctx context.Context, in *urlsv1.GetRequest) (*urlsv1.GetResponse, error) {
+	s.ctx, s.in = ctx, in
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &urlsv1.GetResponse{Url: "https://example.com", Alias: in.GetAlias(), UrlId: 1}, nil
+}
+
+func (s *stubServer) Update(ctx context.Context, in *urlsv1.UpdateRequest) (*urlsv1.UpdateResponse, error) {
+	s.ctx, s.in = ctx, in
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &urlsv1.UpdateResponse{Success: true, NewAlias: in.GetAlias()}, nil
+}
+
+func (s *stubServer) Remove(ctx context.Context, in *urlsv1.RemoveRequest) (*urlsv1.RemoveResponse, error) {
+	s.ctx, s.in = ctx, in
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &urlsv1.RemoveResponse{Success: true}, nil
+}
+
+func (s *stubServer) List(ctx context.Context, in *urlsv1.ListRequest) (*urlsv1.ListResponse, error) {
+	s.ctx, s.in = ctx, in
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &urlsv1.ListResponse{}, nil
+}
+
+func (s *stubServer) Restore(ctx context.Context, in *urlsv1.RemoveRequest) (*urlsv1.GetResponse, error) {
+	s.ctx, s.in = ctx, in
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &urlsv1.GetResponse{Url: "https://example.com", Alias: in.GetAlias(), UrlId: 1}, nil
+}
+
+func newGateway(server rest.UrlSaverServer) *http.ServeMux {
+	mux := http.NewServeMux()
+	rest.Register(mux, slog.New(slog.DiscardHandler), server, stubVerifier{}, stubAuthenticator{})
+
+	return mux
+}
+
+// serve sends the request authenticated by the bearer token when header is
+// empty, otherwise with the given header only
+func serve(mux *http.ServeMux, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if header == nil {
+		r.Header.Set("Authorization", "Bearer "+validToken)
+	} else {
+		r.Header = header
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+
+	return rec
+}
+
+// errorBody is the JSON of google.rpc.Status written on errors
+type errorBody struct {
+	Code    int32            `json:"code"`
+	Message string           `json:"message"`
+	Details []map[string]any `json:"details"`
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	var body errorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body %q is not JSON: %v", rec.Body.String(), err)
+	}
+
+	return body
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantReason string
+	}{
+		{
+			name:       "not found",
+			err:        grpcerr.Resource(codes.NotFound, grpcerr.ReasonAliasNotFound, "url", errors.New("alias not found")),
+			wantStatus: http.StatusNotFound, wantReason: grpcerr.ReasonAliasNotFound,
+		},
+		{
+			name:       "already exists",
+			err:        grpcerr.Resource(codes.AlreadyExists, grpcerr.ReasonAliasExists, "url", errors.New("alias exists")),
+			wantStatus: http.StatusConflict, wantReason: grpcerr.ReasonAliasExists,
+		},
+		{
+			name:       "invalid argument",
+			err:        grpcerr.InvalidArgument("url", "must not be empty"),
+			wantStatus: http.StatusBadRequest, wantReason: grpcerr.ReasonInvalidArgument,
+		},
+		{
+			name:       "unauthenticated",
+			err:        grpcerr.WithDetails(codes.Unauthenticated, "unauthenticated", grpcerr.ReasonUnauthenticated),
+			wantStatus: http.StatusUnauthorized, wantReason: grpcerr.ReasonUnauthenticated,
+		},
+		{
+			name:       "permission denied",
+			err:        grpcerr.WithDetails(codes.PermissionDenied, "forbidden", grpcerr.ReasonApiKeyForbidden),
+			wantStatus: http.StatusForbidden, wantReason: grpcerr.ReasonApiKeyForbidden,
+		},
+		{
+			name:       "shortener unavailable",
+			err:        grpcerr.WithDetails(codes.Unavailable, "unavailable", grpcerr.ReasonShortenerFailed, grpcerr.RetryInfo()),
+			wantStatus: http.StatusServiceUnavailable, wantReason: grpcerr.ReasonShortenerFailed,
+		},
+		{
+			name:       "shortener timeout",
+			err:        grpcerr.WithDetails(codes.DeadlineExceeded, "timeout", grpcerr.ReasonShortenerTimeout),
+			wantStatus: http.StatusGatewayTimeout, wantReason: grpcerr.ReasonShortenerTimeout,
+		},
+		{name: "internal", err: grpcerr.Internal(), wantStatus: http.StatusInternalServerError, wantReason: grpcerr.ReasonInternal},
+		{name: "not a status", err: errors.New("boom"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newGateway(&stubServer{err: tt.err})
+
+			rec := serve(mux, http.MethodGet, "/api/v1/urls/alias", "", nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			body := decodeError(t, rec)
+			st := status.Convert(tt.err)
+			if codes.Code(body.Code) != st.Code() || body.Message != st.Message() {
+				t.Errorf("body = %d %q, want %d %q", body.Code, body.Message, st.Code(), st.Message())
+			}
+
+			if tt.wantReason == "" {
+				return
+			}
+			if len(body.Details) == 0 || body.Details[0]["reason"] != tt.wantReason {
+				t.Errorf("details = %v, want reason %s", body.Details, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{codes.OK, http.StatusOK},
+		{codes.Canceled, 499},
+		{codes.Unknown, http.StatusInternalServerError},
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.AlreadyExists, http.StatusConflict},
+		{codes.PermissionDenied, http.StatusForbidden},
+		{codes.ResourceExhausted, http.StatusTooManyRequests},
+		{codes.FailedPrecondition, http.StatusBadRequest},
+		{codes.Aborted, http.StatusConflict},
+		{codes.OutOfRange, http.StatusBadRequest},
+		{codes.Unimplemented, http.StatusNotImplemented},
+		{codes.Internal, http.StatusInternalServerError},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.DataLoss, http.StatusInternalServerError},
+		{codes.Unauthenticated, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			if got := rest.HTTPStatusFromCode(tt.code); got != tt.want {
+				t.Errorf("HTTPStatusFromCode(%s) = %d, want %d", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "save", method: http.MethodPost, target: "/api/v1/urls", body: `{"url": "https://example.com"}`,
+			wantStatus: http.StatusCreated, wantBody: `"alias":"alias"`,
+		},
+		{name: "get", method: http.MethodGet, target: "/api/v1/urls/alias", wantStatus: http.StatusOK, wantBody: `"url_id":"1"`},
+		{
+			name: "update", method: http.MethodPatch, target: "/api/v1/urls/alias", body: `{"new_url": "https://example.com"}`,
+			wantStatus: http.StatusOK, wantBody: `"success":true`,
+		},
+		{name: "remove", method: http.MethodDelete, target: "/api/v1/urls/alias", wantStatus: http.StatusOK, wantBody: `"success":true`},
+		{name: "restore", method: http.MethodPost, target: "/api/v1/urls/alias/restore", wantStatus: http.StatusOK, wantBody: `"alias":"alias"`},
+		{name: "list", method: http.MethodGet, target: "/api/v1/urls?page_size=10", wantStatus: http.StatusOK},
+		{name: "save bad body", method: http.MethodPost, target: "/api/v1/urls", body: `[]`, wantStatus: http.StatusBadRequest},
+		{name: "save not json", method: http.MethodPost, target: "/api/v1/urls", body: `url=x`, wantStatus: http.StatusBadRequest},
+		{name: "save bad meta", method: http.MethodPost, target: "/api/v1/urls", body: `{"tags": "go"}`, wantStatus: http.StatusBadRequest},
+		{name: "update bad body", method: http.MethodPatch, target: "/api/v1/urls/alias", body: `{"new_url": 1}`, wantStatus: http.StatusBadRequest},
+		{name: "list bad page size", method: http.MethodGet, target: "/api/v1/urls?page_size=ten", wantStatus: http.StatusBadRequest},
+		{name: "unknown method", method: http.MethodPut, target: "/api/v1/urls/alias", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newGateway(&stubServer{}), tt.method, tt.target, tt.body, nil)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRequestMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   map[string]string
+	}{
+		{
+			name: "save meta", method: http.MethodPost, target: "/api/v1/urls",
+			body: `{"url": "https://example.com", "title": "Example", "tags": ["a", "b"], "ttl": "1h", "dedupe": true}`,
+			want: map[string]string{
+				grpcurlsaver.MetaUrlTitle: "Example",
+				grpcurlsaver.MetaUrlTags:  "a,b",
+				grpcurlsaver.MetaUrlTTL:   "1h",
+				grpcurlsaver.MetaDedupe:   "true",
+			},
+		},
+		{
+			name: "update expiration", method: http.MethodPatch, target: "/api/v1/urls/alias",
+			body: `{"expires_at": "2030-01-01T00:00:00Z"}`,
+			want: map[string]string{grpcurlsaver.MetaUrlExpiresAt: "2030-01-01T00:00:00Z"},
+		},
+		{
+			name: "list filter", method: http.MethodGet,
+			target: "/api/v1/urls?domain=example.com&tag=go&q=text&sort_by=alias&sort_order=asc",
+			want: map[string]string{
+				grpcurlsaver.MetaFilterDomain: "example.com",
+				grpcurlsaver.MetaFilterTag:    "go",
+				grpcurlsaver.MetaFilterQuery:  "text",
+				grpcurlsaver.MetaSortBy:       "alias",
+				grpcurlsaver.MetaSortOrder:    "asc",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &stubServer{}
+			if rec := serve(newGateway(server), tt.method, tt.target, tt.body, nil); rec.Code >= http.StatusBadRequest {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+
+			md, _ := metadata.FromIncomingContext(server.ctx)
+			for key, want := range tt.want {
+				if got := md.Get(key); len(got) != 1 || got[0] != want {
+					t.Errorf("metadata %s = %v, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     http.Header
+		wantStatus int
+		wantApiKey bool
+	}{
+		{name: "bearer token", header: http.Header{"Authorization": {"Bearer " + validToken}}, wantStatus: http.StatusOK},
+		{name: "lowercase scheme", header: http.Header{"Authorization": {"bearer " + validToken}}, wantStatus: http.StatusOK},
+		{name: "api key", header: http.Header{"X-Api-Key": {validApiKey}}, wantStatus: http.StatusOK, wantApiKey: true},
+		{
+			name:       "api key before token",
+			header:     http.Header{"X-Api-Key": {validApiKey}, "Authorization": {"Bearer invalid"}},
+			wantStatus: http.StatusOK, wantApiKey: true,
+		},
+		{
+			name:       "invalid api key with valid token",
+			header:     http.Header{"X-Api-Key": {"invalid"}, "Authorization": {"Bearer " + validToken}},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{name: "no credentials", header: http.Header{}, wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", header: http.Header{"Authorization": {"Bearer invalid"}}, wantStatus: http.StatusUnauthorized},
+		{name: "basic scheme", header: http.Header{"Authorization": {"Basic " + validToken}}, wantStatus: http.StatusUnauthorized},
+		{name: "empty token", header: http.Header{"Authorization": {"Bearer "}}, wantStatus: http.StatusUnauthorized},
+		{name: "authenticator failure", header: http.Header{"X-Api-Key": {brokenKey}}, wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &stubServer{}
+			rec := serve(newGateway(server), http.MethodGet, "/api/v1/urls/alias", "", tt.header)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				decodeError(t, rec)
+				if server.ctx != nil {
+					t.Error("rejected request reached the server")
+				}
+				return
+			}
+
+			if got, ok := userctx.UserID(server.ctx); !ok || got != userID {
+				t.Errorf("user ID = %d, %t, want %d", got, ok, userID)
+			}
+			if got := userctx.ByApiKey(server.ctx); got != tt.wantApiKey {
+				t.Errorf("ByApiKey() = %t, want %t", got, tt.wantApiKey)
+			}
+		})
+	}
+}
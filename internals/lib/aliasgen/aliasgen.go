@@ -0,0 +1,113 @@
+package aliasgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	KindRandom     = "random"
+	KindSequential = "sequential"
+	KindWords      = "words"
+
+	// Base62 is the default alphabet, its aliases are safe in any url path
+	Base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// maxLength is the longest alias the API accepts
+	maxLength = 50
+)
+
+var (
+	ErrUnknownKind     = errors.New("unknown alias generator")
+	ErrInvalidAlphabet = errors.New("alphabet must have at least 2 unique ASCII letters, digits or any of '-', '_', '.', '~'")
+	ErrInvalidLength   = errors.New("alias length must be from 1 to 50")
+)
+
+// Generator returns new candidate aliases. Uniqueness is not guaranteed,
+// the caller retries on collision
+type Generator interface {
+	Generate() (alias string, err error)
+}
+
+// New returns generator of the given kind. Alphabet and length are used by
+// random and sequential generators, words is the number of words of a slug
+func New(kind, alphabet string, length, words int) (Generator, error) {
+	switch kind {
+	case KindRandom, "":
+		return NewRandom(alphabet, length)
+	case KindSequential:
+		return NewSequential(alphabet, length)
+	case KindWords:
+		return NewWords(words)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKind, kind)
+	}
+}
+
+// Random generates aliases of random characters of the alphabet
+type Random struct {
+	alphabet string
+	length   int
+}
+
+func NewRandom(alphabet string, length int) (*Random, error) {
+	if err := validate(alphabet, length); err != nil {
+		return nil, err
+	}
+
+	return &Random{alphabet: alphabet, length: length}, nil
+}
+
+func (r *Random) Generate() (string, error) {
+	alias := make([]byte, r.length)
+	for i := range alias {
+		n, err := randomInt(len(r.alphabet))
+		if err != nil {
+			return "", err
+		}
+		alias[i] = r.alphabet[n]
+	}
+
+	return string(alias), nil
+}
+
+func validate(alphabet string, length int) error {
+	if length < 1 || length > maxLength {
+		return ErrInvalidLength
+	}
+
+	if len(alphabet) < 2 {
+		return ErrInvalidAlphabet
+	}
+	seen := make(map[byte]bool, len(alphabet))
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if c >= utf8.RuneSelf || !IsAliasRune(rune(c)) || seen[c] {
+			return ErrInvalidAlphabet
+		}
+		seen[c] = true
+	}
+
+	return nil
+}
+
+// IsAliasRune reports whether the rune may be a part of alias, every alias
+// is a single segment of the redirect path
+func IsAliasRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_.~", r)
+}
+
+// randomInt returns uniformly distributed number in [0, n)
+func randomInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(v.Int64()), nil
+}
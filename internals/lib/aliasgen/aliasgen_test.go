@@ -0,0 +1,162 @@
+package aliasgen_test
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nhassl3/url-saver/internals/lib/aliasgen"
+)
+
+const samples = 1000
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		alphabet string
+		length   int
+		words    int
+		wantErr  error
+	}{
+		{name: "default", alphabet: aliasgen.Base62, length: 8},
+		{name: "random", kind: aliasgen.KindRandom, alphabet: aliasgen.Base62, length: 8},
+		{name: "sequential", kind: aliasgen.KindSequential, alphabet: aliasgen.Base62, length: 8},
+		{name: "words", kind: aliasgen.KindWords, words: 2},
+		{name: "unknown kind", kind: "uuid", alphabet: aliasgen.Base62, length: 8, wantErr: aliasgen.ErrUnknownKind},
+
+		{name: "shortest", alphabet: "ab", length: 1},
+		{name: "longest", alphabet: "ab", length: 50},
+		{name: "url safe symbols", alphabet: "ab-_.~", length: 8},
+		{name: "zero length", alphabet: aliasgen.Base62, length: 0, wantErr: aliasgen.ErrInvalidLength},
+		{name: "too long", alphabet: aliasgen.Base62, length: 51, wantErr: aliasgen.ErrInvalidLength},
+		{name: "empty alphabet", alphabet: "", length: 8, wantErr: aliasgen.ErrInvalidAlphabet},
+		{name: "one letter alphabet", alphabet: "a", length: 8, wantErr: aliasgen.ErrInvalidAlphabet},
+		{name: "repeated letters", alphabet: "abca", length: 8, wantErr: aliasgen.ErrInvalidAlphabet},
+		{name: "slash", alphabet: "ab/", length: 8, wantErr: aliasgen.ErrInvalidAlphabet},
+		{name: "space", alphabet: "ab ", length: 8, wantErr: aliasgen.ErrInvalidAlphabet},
+		{name: "not ascii", alphabet: "abé", length: 8, wantErr: aliasgen.ErrInvalidAlphabet},
+		{
+			name: "sequential invalid alphabet", kind: aliasgen.KindSequential, alphabet: "a", length: 8,
+			wantErr: aliasgen.ErrInvalidAlphabet,
+		},
+		{name: "no words", kind: aliasgen.KindWords, words: 0, wantErr: aliasgen.ErrInvalidWords},
+		{name: "too many words", kind: aliasgen.KindWords, words: 6, wantErr: aliasgen.ErrInvalidWords},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := aliasgen.New(tt.kind, tt.alphabet, tt.length, tt.words)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("New() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && gen == nil {
+				t.Fatal("New() returned nil generator")
+			}
+		})
+	}
+}
+
+func TestGenerateLengthAndCharset(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		alphabet string
+		length   int
+	}{
+		{name: "random base62", kind: aliasgen.KindRandom, alphabet: aliasgen.Base62, length: 8},
+		{name: "random binary", kind: aliasgen.KindRandom, alphabet: "01", length: 50},
+		{name: "random single letter", kind: aliasgen.KindRandom, alphabet: "xyz", length: 1},
+		{name: "sequential base62", kind: aliasgen.KindSequential, alphabet: aliasgen.Base62, length: 8},
+		{name: "sequential symbols", kind: aliasgen.KindSequential, alphabet: "-_.~", length: 12},
+		{name: "sequential single letter", kind: aliasgen.KindSequential, alphabet: "ab", length: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := aliasgen.New(tt.kind, tt.alphabet, tt.length, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			used := make(map[rune]bool)
+			for range samples {
+				alias, err := gen.Generate()
+				if err != nil {
+					t.Fatalf("Generate() error = %v", err)
+				}
+				if len(alias) != tt.length {
+					t.Fatalf("Generate() = %q, want length %d", alias, tt.length)
+				}
+				for _, r := range alias {
+					if !strings.ContainsRune(tt.alphabet, r) {
+						t.Fatalf("Generate() = %q, %q is not in the alphabet %q", alias, r, tt.alphabet)
+					}
+					used[r] = true
+				}
+			}
+
+			if len(used) != len(tt.alphabet) {
+				t.Errorf("%d aliases used %d of %d letters", samples, len(used), len(tt.alphabet))
+			}
+		})
+	}
+}
+
+func TestSequentialDoesNotRepeat(t *testing.T) {
+	// 4^5 = 1024 aliases, the space is exhausted only after all of them
+	gen, err := aliasgen.NewSequential("abcd", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	var previous string
+	for range 1024 {
+		alias, err := gen.Generate()
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if seen[alias] {
+			t.Fatalf("Generate() repeated %q after %d aliases", alias, len(seen))
+		}
+		if previous != "" && alias[:4] == previous[:4] {
+			t.Errorf("consecutive aliases %q and %q look alike", previous, alias)
+		}
+		seen[alias] = true
+		previous = alias
+	}
+
+	alias, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if !seen[alias] {
+		t.Errorf("Generate() = %q out of the exhausted space", alias)
+	}
+}
+
+func TestWords(t *testing.T) {
+	for words := 1; words <= 5; words++ {
+		gen, err := aliasgen.NewWords(words)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pattern := regexp.MustCompile(fmt.Sprintf(`^([a-z]+-){%d}[0-9]{2}$`, words))
+		for range samples {
+			alias, err := gen.Generate()
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if !pattern.MatchString(alias) {
+				t.Fatalf("NewWords(%d).Generate() = %q, want %s", words, alias, pattern)
+			}
+			for _, r := range alias {
+				if !aliasgen.IsAliasRune(r) {
+					t.Fatalf("Generate() = %q, %q may not be a part of alias", alias, r)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package aliasgen
+
+import (
+	"math/big"
+	"sync"
+	"time"
+)
+
+// multiplier is a large odd constant, multiplying by it mod the alias space
+// moves consecutive numbers far away from each other
+var multiplier = big.NewInt(6364136223846793005)
+
+// Sequential generates aliases from an increasing counter, like hashids.
+// Every number is mapped one-to-one onto the space of aliases of the given
+// length, so aliases don't repeat until the space is exhausted and yet
+// neighbouring aliases don't look alike.
+// The counter starts from the current time in milliseconds, so after
+// restart it continues above the numbers used before as long as less than
+// a thousand aliases per second were generated
+type Sequential struct {
+	alphabet string
+	length   int
+	space    *big.Int
+	factor   *big.Int
+
+	mu      sync.Mutex
+	counter *big.Int
+}
+
+func NewSequential(alphabet string, length int) (*Sequential, error) {
+	if err := validate(alphabet, length); err != nil {
+		return nil, err
+	}
+
+	space := new(big.Int).Exp(big.NewInt(int64(len(alphabet))), big.NewInt(int64(length)), nil)
+
+	// the factor must be coprime with the space to make the mapping one-to-one
+	factor := new(big.Int).Mod(multiplier, space)
+	one := big.NewInt(1)
+	for factor.Sign() == 0 || new(big.Int).GCD(nil, nil, factor, space).Cmp(one) != 0 {
+		factor.Add(factor, one)
+		factor.Mod(factor, space)
+	}
+
+	return &Sequential{
+		alphabet: alphabet,
+		length:   length,
+		space:    space,
+		factor:   factor,
+		counter:  big.NewInt(time.Now().UnixMilli()),
+	}, nil
+}
+
+func (s *Sequential) Generate() (string, error) {
+	s.mu.Lock()
+	n := new(big.Int).Set(s.counter)
+	s.counter.Add(s.counter, big.NewInt(1))
+	s.mu.Unlock()
+
+	n.Mul(n, s.factor)
+	n.Mod(n, s.space)
+
+	base := big.NewInt(int64(len(s.alphabet)))
+	digit := new(big.Int)
+	alias := make([]byte, s.length)
+	for i := s.length - 1; i >= 0; i-- {
+		n.DivMod(n, base, digit)
+		alias[i] = s.alphabet[digit.Int64()]
+	}
+
+	return string(alias), nil
+}
@@ -0,0 +1,68 @@
+package aliasgen
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const maxWords = 5
+
+var ErrInvalidWords = errors.New("number of words must be from 1 to 5")
+
+var (
+	adjectives = []string{
+		"able", "bold", "brave", "bright", "calm", "clever", "cool", "crisp",
+		"eager", "fair", "fancy", "fast", "fresh", "gentle", "glad", "golden",
+		"grand", "happy", "keen", "kind", "lively", "lucky", "merry", "mighty",
+		"neat", "noble", "proud", "quick", "quiet", "rapid", "sharp", "shiny",
+		"silent", "simple", "smart", "smooth", "solid", "sunny", "swift", "tidy",
+		"tiny", "vivid", "warm", "wild", "wise", "witty", "young", "zesty",
+	}
+	nouns = []string{
+		"apple", "badger", "bear", "breeze", "brook", "canyon", "cedar", "cloud",
+		"comet", "coral", "crane", "dawn", "delta", "eagle", "falcon", "fern",
+		"field", "forest", "fox", "glade", "harbor", "hawk", "heron", "island",
+		"lake", "lark", "maple", "meadow", "moon", "moose", "otter", "owl",
+		"panda", "pebble", "pine", "planet", "puma", "raven", "reef", "river",
+		"robin", "sparrow", "star", "stone", "tiger", "valley", "willow", "wolf",
+	}
+)
+
+// Words generates readable slugs like "brave-otter-42": adjectives
+// followed by a noun and a two digits number
+type Words struct {
+	words int
+}
+
+func NewWords(words int) (*Words, error) {
+	if words < 1 || words > maxWords {
+		return nil, ErrInvalidWords
+	}
+
+	return &Words{words: words}, nil
+}
+
+func (w *Words) Generate() (string, error) {
+	parts := make([]string, 0, w.words+1)
+	for i := 0; i < w.words; i++ {
+		list := adjectives
+		if i == w.words-1 {
+			list = nouns
+		}
+
+		n, err := randomInt(len(list))
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, list[n])
+	}
+
+	n, err := randomInt(100)
+	if err != nil {
+		return "", err
+	}
+	parts = append(parts, fmt.Sprintf("%02d", n))
+
+	return strings.Join(parts, "-"), nil
+}
@@ -0,0 +1,101 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const (
+	opNewVerifier = "jwt.NewVerifier"
+	opVerify      = "jwt.Verify"
+)
+
+var (
+	ErrNoKeys       = errors.New("neither HMAC secret nor RSA public key is configured")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Verifier checks bearer tokens signed with HS256 by the shared secret
+// or with RS256 by the private pair of the configured public key
+type Verifier struct {
+	hmacSecret   []byte
+	rsaPublicKey *rsa.PublicKey
+	parser       *jwt.Parser
+}
+
+// NewVerifier creates token verifier. At least one of the HMAC secret
+// and the path to PEM encoded RSA public key must be set. Empty issuer
+// or audience are not checked
+func NewVerifier(hmacSecret, rsaPublicKeyPath, issuer, audience string) (*Verifier, error) {
+	v := &Verifier{}
+
+	methods := make([]string, 0, 2)
+	if hmacSecret != "" {
+		v.hmacSecret = []byte(hmacSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	if rsaPublicKeyPath != "" {
+		pemKey, err := os.ReadFile(rsaPublicKeyPath)
+		if err != nil {
+			return nil, sl.Wrap(opNewVerifier, err)
+		}
+
+		if v.rsaPublicKey, err = jwt.ParseRSAPublicKeyFromPEM(pemKey); err != nil {
+			return nil, sl.Wrap(opNewVerifier, err)
+		}
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	if len(methods) == 0 {
+		return nil, sl.Wrap(opNewVerifier, ErrNoKeys)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+// Verify checks signature and claims of the token and returns ID of the
+// user from its subject
+func (v *Verifier) Verify(token string) (userID int64, err error) {
+	claims := jwt.RegisteredClaims{}
+
+	if _, err = v.parser.ParseWithClaims(token, &claims, v.key); err != nil {
+		return 0, sl.Wrap(opVerify, fmt.Errorf("%w: %w", ErrInvalidToken, err))
+	}
+
+	userID, err = strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || userID <= 0 {
+		return 0, sl.Wrap(opVerify, fmt.Errorf("%w: subject is not a user ID", ErrInvalidToken))
+	}
+
+	return userID, nil
+}
+
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.hmacSecret, nil
+	case *jwt.SigningMethodRSA:
+		return v.rsaPublicKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
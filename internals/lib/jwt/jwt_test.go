@@ -0,0 +1,169 @@
+package jwt_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/nhassl3/url-saver/internals/lib/jwt"
+)
+
+const (
+	secret   = "test-secret"
+	issuer   = "url-saver"
+	audience = "clients"
+)
+
+// writePublicKey writes PEM encoded public key of the pair and returns its path
+func writePublicKey(t *testing.T, key *rsa.PrivateKey) (path string, pemKey []byte) {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	path = filepath.Join(t.TempDir(), "public.pem")
+	if err := os.WriteFile(path, pemKey, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, pemKey
+}
+
+func claims(subject string, expiresIn time.Duration) gojwt.RegisteredClaims {
+	return gojwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    issuer,
+		Audience:  gojwt.ClaimStrings{audience},
+		ExpiresAt: gojwt.NewNumericDate(time.Now().Add(expiresIn)),
+	}
+}
+
+func sign(t *testing.T, method gojwt.SigningMethod, c gojwt.Claims, key any) string {
+	t.Helper()
+
+	token, err := gojwt.NewWithClaims(method, c).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPath, publicKeyPEM := writePublicKey(t, rsaKey)
+
+	verifier, err := jwt.NewVerifier(secret, publicKeyPath, issuer, audience)
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	valid := claims("42", time.Hour)
+	noExpiration := claims("42", 0)
+	noExpiration.ExpiresAt = nil
+	wrongIssuer := claims("42", time.Hour)
+	wrongIssuer.Issuer = "other"
+	wrongAudience := claims("42", time.Hour)
+	wrongAudience.Audience = gojwt.ClaimStrings{"other"}
+	notYetValid := claims("42", time.Hour)
+	notYetValid.NotBefore = gojwt.NewNumericDate(time.Now().Add(time.Minute))
+
+	tests := []struct {
+		name  string
+		token string
+		// want is ID of the user, zero means the token is rejected
+		want int64
+	}{
+		{name: "hs256", token: sign(t, gojwt.SigningMethodHS256, valid, []byte(secret)), want: 42},
+		{name: "rs256", token: sign(t, gojwt.SigningMethodRS256, valid, rsaKey), want: 42},
+		{name: "expired", token: sign(t, gojwt.SigningMethodHS256, claims("42", -time.Minute), []byte(secret))},
+		{name: "without expiration", token: sign(t, gojwt.SigningMethodHS256, noExpiration, []byte(secret))},
+		{name: "not yet valid", token: sign(t, gojwt.SigningMethodHS256, notYetValid, []byte(secret))},
+		{name: "wrong secret", token: sign(t, gojwt.SigningMethodHS256, valid, []byte("other-secret"))},
+		{name: "wrong rsa key", token: sign(t, gojwt.SigningMethodRS256, valid, otherKey)},
+		{name: "alg none", token: sign(t, gojwt.SigningMethodNone, valid, gojwt.UnsafeAllowNoneSignatureType)},
+		{name: "hs512", token: sign(t, gojwt.SigningMethodHS512, valid, []byte(secret))},
+		// the public key is known to everyone, it must not be taken as HMAC secret
+		{name: "hs256 signed with public key", token: sign(t, gojwt.SigningMethodHS256, valid, publicKeyPEM)},
+		{name: "wrong issuer", token: sign(t, gojwt.SigningMethodHS256, wrongIssuer, []byte(secret))},
+		{name: "wrong audience", token: sign(t, gojwt.SigningMethodHS256, wrongAudience, []byte(secret))},
+		{name: "subject is not a number", token: sign(t, gojwt.SigningMethodHS256, claims("admin", time.Hour), []byte(secret))},
+		{name: "zero subject", token: sign(t, gojwt.SigningMethodHS256, claims("0", time.Hour), []byte(secret))},
+		{name: "negative subject", token: sign(t, gojwt.SigningMethodHS256, claims("-1", time.Hour), []byte(secret))},
+		{name: "empty", token: ""},
+		{name: "garbage", token: "not.a.token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, err := verifier.Verify(tt.token)
+			if tt.want != 0 {
+				if err != nil || userID != tt.want {
+					t.Errorf("Verify() = %d, %v, want %d", userID, err, tt.want)
+				}
+				return
+			}
+			if !errors.Is(err, jwt.ErrInvalidToken) {
+				t.Errorf("Verify() = %d, %v, want %v", userID, err, jwt.ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestVerifyOnlyConfiguredMethods(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPath, _ := writePublicKey(t, rsaKey)
+
+	hmacOnly, err := jwt.NewVerifier(secret, "", "", "")
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	rsaOnly, err := jwt.NewVerifier("", publicKeyPath, "", "")
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	if _, err := hmacOnly.Verify(sign(t, gojwt.SigningMethodRS256, claims("1", time.Hour), rsaKey)); !errors.Is(err, jwt.ErrInvalidToken) {
+		t.Errorf("Verify() of rs256 token by hmac verifier error = %v, want %v", err, jwt.ErrInvalidToken)
+	}
+	// empty secret of the rsa verifier must not accept hmac tokens
+	if _, err := rsaOnly.Verify(sign(t, gojwt.SigningMethodHS256, claims("1", time.Hour), []byte(""))); !errors.Is(err, jwt.ErrInvalidToken) {
+		t.Errorf("Verify() of hs256 token by rsa verifier error = %v, want %v", err, jwt.ErrInvalidToken)
+	}
+}
+
+func TestNewVerifierRejects(t *testing.T) {
+	if _, err := jwt.NewVerifier("", "", issuer, audience); !errors.Is(err, jwt.ErrNoKeys) {
+		t.Errorf("NewVerifier() without keys error = %v, want %v", err, jwt.ErrNoKeys)
+	}
+	if _, err := jwt.NewVerifier("", filepath.Join(t.TempDir(), "missing.pem"), "", ""); err == nil {
+		t.Error("NewVerifier() with missing public key succeeded")
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(notPEM, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.NewVerifier(secret, notPEM, "", ""); err == nil {
+		t.Error("NewVerifier() with invalid public key succeeded")
+	}
+}
@@ -1,8 +1,9 @@
 package sl
 
 import (
-	"fmt"
+	"errors"
 	"log/slog"
+	"strings"
 )
 
 func Err(err error) slog.Attr {
@@ -12,6 +13,48 @@ func Err(err error) slog.Attr {
 	}
 }
 
-func ErrUpLevel(handleName, err string) error {
-	return fmt.Errorf(handleName + ": " + err)
+// OpError is an error occurred in the named operation. It keeps the cause,
+// so errors.Is and errors.As still match the sentinel errors of lower levels
+type OpError struct {
+	Op  string
+	Err error
+}
+
+func (e *OpError) Error() string {
+	return e.Op + ": " + e.Err.Error()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap wraps err with the operation name. Returns nil if err is nil
+func Wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &OpError{Op: op, Err: err}
+}
+
+// Ops returns the stack of operations the error passed through,
+// from the outermost one to the place where it occurred
+func Ops(err error) []string {
+	var ops []string
+
+	for err != nil {
+		var opErr *OpError
+		if !errors.As(err, &opErr) {
+			break
+		}
+		ops = append(ops, opErr.Op)
+		err = opErr.Err
+	}
+
+	return ops
+}
+
+// OpStack returns slog attribute with the operations stack of the error
+func OpStack(err error) slog.Attr {
+	return slog.String("op_stack", strings.Join(Ops(err), " <- "))
 }
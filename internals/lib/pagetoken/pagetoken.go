@@ -0,0 +1,76 @@
+package pagetoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+)
+
+var ErrInvalidToken = errors.New("invalid page token")
+
+// Codec encodes list cursors into opaque page tokens. Tokens are signed with
+// HMAC-SHA256 and bound to the user and the scope of the list (filter and
+// sort order), so they can't be forged or reused with another list
+type Codec struct {
+	secret []byte
+}
+
+func NewCodec(secret []byte) *Codec {
+	return &Codec{secret: secret}
+}
+
+// Encode returns token in form base64(cursor).base64(signature)
+func (c *Codec) Encode(userID int64, scope string, cursor entities.Cursor) string {
+	// cursor consists of plain fields, marshaling can't fail
+	payload, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(c.sign(userID, scope, payload))
+}
+
+func (c *Codec) Decode(userID int64, scope, token string) (entities.Cursor, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return entities.Cursor{}, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return entities.Cursor{}, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, c.sign(userID, scope, payload)) {
+		return entities.Cursor{}, ErrInvalidToken
+	}
+
+	var cursor entities.Cursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return entities.Cursor{}, ErrInvalidToken
+	}
+
+	return cursor, nil
+}
+
+func (c *Codec) sign(userID int64, scope string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+
+	var user [8]byte
+	binary.BigEndian.PutUint64(user[:], uint64(userID))
+	mac.Write(user[:])
+
+	// length prefix keeps scope and payload from running into each other
+	var scopeLen [8]byte
+	binary.BigEndian.PutUint64(scopeLen[:], uint64(len(scope)))
+	mac.Write(scopeLen[:])
+	mac.Write([]byte(scope))
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
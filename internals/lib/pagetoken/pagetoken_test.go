@@ -0,0 +1,98 @@
+package pagetoken_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/pagetoken"
+)
+
+const (
+	userID = 1
+	scope  = "sort=created_at&order=desc&tag=go"
+)
+
+var cursor = entities.Cursor{
+	CreatedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+	UpdatedAt: time.Date(2026, 10, 2, 12, 0, 0, 0, time.UTC),
+	Alias:     "page",
+	ID:        42,
+}
+
+func TestRoundTrip(t *testing.T) {
+	codec := pagetoken.NewCodec([]byte("secret"))
+
+	got, err := codec.Decode(userID, scope, codec.Encode(userID, scope, cursor))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(cursor.CreatedAt) || !got.UpdatedAt.Equal(cursor.UpdatedAt) ||
+		got.Alias != cursor.Alias || got.ID != cursor.ID {
+		t.Errorf("Decode() = %+v, want %+v", got, cursor)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	codec := pagetoken.NewCodec([]byte("secret"))
+	token := codec.Encode(userID, scope, cursor)
+	payload, signature, _ := strings.Cut(token, ".")
+
+	// forged cursor signed by nobody, the payload is valid json
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"ID":1}`)) + "." + signature
+
+	tests := []struct {
+		name   string
+		codec  *pagetoken.Codec
+		userID int64
+		scope  string
+		token  string
+	}{
+		{name: "empty", token: ""},
+		{name: "no signature", token: payload},
+		{name: "empty signature", token: payload + "."},
+		{name: "truncated signature", token: token[:len(token)-2]},
+		{name: "truncated payload", token: payload[1:] + "." + signature},
+		{name: "tampered payload", token: forged},
+		{name: "tampered signature", token: payload + "." + flipLast(signature)},
+		{name: "not base64", token: "!!!." + signature},
+		{name: "extra part", token: token + ".extra"},
+		{name: "other user", userID: userID + 1, token: token},
+		{name: "other filter", scope: "sort=created_at&order=desc&tag=rust", token: token},
+		{name: "other order", scope: "sort=created_at&order=asc&tag=go", token: token},
+		// scope and payload are length prefixed, moving bytes between them fails
+		{name: "scope running into payload", scope: scope[:len(scope)-1], token: token},
+		{name: "other secret", codec: pagetoken.NewCodec([]byte("other-secret")), token: token},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, user, s := codec, int64(userID), scope
+			if tt.codec != nil {
+				c = tt.codec
+			}
+			if tt.userID != 0 {
+				user = tt.userID
+			}
+			if tt.scope != "" {
+				s = tt.scope
+			}
+
+			if got, err := c.Decode(user, s, tt.token); !errors.Is(err, pagetoken.ErrInvalidToken) {
+				t.Errorf("Decode() = %+v, %v, want %v", got, err, pagetoken.ErrInvalidToken)
+			}
+		})
+	}
+}
+
+// flipLast changes the last character of base64 text to another valid one
+func flipLast(s string) string {
+	last := s[len(s)-1]
+	if last == 'A' {
+		return s[:len(s)-1] + "B"
+	}
+
+	return s[:len(s)-1] + "A"
+}
@@ -0,0 +1,30 @@
+package userctx
+
+import "context"
+
+type ctxKey struct{}
+
+// WithUserID returns copy of the context carrying ID of the calling user
+func WithUserID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, ctxKey{}, userID)
+}
+
+// UserID returns ID of the calling user stored in the context
+func UserID(ctx context.Context) (userID int64, ok bool) {
+	userID, ok = ctx.Value(ctxKey{}).(int64)
+	return
+}
+
+type apiKeyCtxKey struct{}
+
+// WithApiKey returns copy of the context of the request authenticated by
+// an api key instead of a token
+func WithApiKey(ctx context.Context) context.Context {
+	return context.WithValue(ctx, apiKeyCtxKey{}, true)
+}
+
+// ByApiKey reports whether the calling user is authenticated by an api key
+func ByApiKey(ctx context.Context) bool {
+	byApiKey, _ := ctx.Value(apiKeyCtxKey{}).(bool)
+	return byApiKey
+}
@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/storage"
+)
+
+const (
+	opUrl       = "cache.Url"
+	opPublicUrl = "cache.PublicUrl"
+)
+
+// Storage is the url storage the cache reads through
+type Storage interface {
+	urlsaver.SaverUrl
+	urlsaver.ProviderUrl
+	urlsaver.UpdaterUrl
+}
+
+// Stats are counters of the cache since it was created
+type Stats struct {
+	Hits int64 `json:"hits"`
+	// NegativeHits are hits of aliases known to be not found,
+	// they are counted in Hits too
+	NegativeHits int64 `json:"negative_hits"`
+	Misses       int64 `json:"misses"`
+	Evictions    int64 `json:"evictions"`
+	Size         int   `json:"size"`
+}
+
+// key is the alias of the user, or the alias among urls of all users
+// when public is set
+type key struct {
+	public bool
+	userID int64
+	alias  string
+}
+
+type entry struct {
+	key key
+	url entities.URL
+	// found is false for aliases which are not found
+	found     bool
+	expiresAt time.Time
+}
+
+// UrlCache caches alias lookups of the storage in an LRU. Unknown aliases
+// are cached too, for negativeTTL. Found urls are kept no longer than until
+// they expire. Writes going through the cache evict the aliases they touch,
+// so its users see their own changes. Changes made around it, by other
+// instances, are seen once the entries expire
+type UrlCache struct {
+	Storage
+
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[key]*list.Element
+	// lru keeps entries from the most recently used one
+	lru *list.List
+	// byID keeps keys of found entries of every url, they are evicted
+	// when the url is changed by ID
+	byID map[int64]map[key]struct{}
+	// epoch changes with every write. Lookups which started before the
+	// write may have read the old value, so they don't cache it
+	epoch uint64
+
+	hits         atomic.Int64
+	negativeHits atomic.Int64
+	misses       atomic.Int64
+	evictions    atomic.Int64
+}
+
+// NewUrlCache creates cache of up to size lookups of the storage. Found urls
+// are kept for ttl, unknown aliases for negativeTTL
+func NewUrlCache(urlStorage Storage, size int, ttl, negativeTTL time.Duration) *UrlCache {
+	return &UrlCache{
+		Storage:     urlStorage,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		entries:     make(map[key]*list.Element, size),
+		lru:         list.New(),
+		byID:        make(map[int64]map[key]struct{}),
+	}
+}
+
+// Stats returns current counters of the cache
+func (c *UrlCache) Stats() Stats {
+	c.mu.Lock()
+	size := c.lru.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:         c.hits.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		Misses:       c.misses.Load(),
+		Evictions:    c.evictions.Load(),
+		Size:         size,
+	}
+}
+
+func (c *UrlCache) Url(ctx context.Context, userID int64, alias string) (entities.URL, error) {
+	return c.lookup(ctx, opUrl, key{userID: userID, alias: alias}, func() (entities.URL, error) {
+		return c.Storage.Url(ctx, userID, alias)
+	})
+}
+
+func (c *UrlCache) PublicUrl(ctx context.Context, alias string) (entities.URL, error) {
+	return c.lookup(ctx, opPublicUrl, key{public: true, alias: alias}, func() (entities.URL, error) {
+		return c.Storage.PublicUrl(ctx, alias)
+	})
+}
+
+// lookup returns the cached result of the key or reads it through
+func (c *UrlCache) lookup(
+	ctx context.Context,
+	op string,
+	k key,
+	read func() (entities.URL, error),
+) (entities.URL, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.URL{}, sl.Wrap(op, err)
+	}
+
+	c.mu.Lock()
+	if e, ok := c.get(k); ok {
+		c.mu.Unlock()
+
+		c.hits.Add(1)
+		if !e.found {
+			c.negativeHits.Add(1)
+			return entities.URL{}, sl.Wrap(op, storage.ErrAliasNotFound)
+		}
+		return e.url, nil
+	}
+	epoch := c.epoch
+	c.mu.Unlock()
+
+	c.misses.Add(1)
+
+	url, err := read()
+	if err != nil && !errors.Is(err, storage.ErrAliasNotFound) {
+		return entities.URL{}, err
+	}
+
+	c.mu.Lock()
+	if c.epoch == epoch {
+		c.put(k, url, err == nil)
+	}
+	c.mu.Unlock()
+
+	return url, err
+}
+
+func (c *UrlCache) SaveUrl(ctx context.Context, userID int64, url, alias string, meta entities.UrlMeta) (int64, error) {
+	// in case of error the alias may be saved as well, e.g. when the
+	// commit fails after it is done
+	defer c.invalidate(0, key{userID: userID, alias: alias})
+
+	return c.Storage.SaveUrl(ctx, userID, url, alias, meta)
+}
+
+// UpdateUrl evicts the new alias and every cached key of the url, which
+// covers its old alias
+func (c *UrlCache) UpdateUrl(ctx context.Context, userID, urlID int64, url, alias string, meta entities.UrlMeta) error {
+	defer c.invalidate(urlID, key{userID: userID, alias: alias})
+
+	return c.Storage.UpdateUrl(ctx, userID, urlID, url, alias, meta)
+}
+
+func (c *UrlCache) RemoveUrl(ctx context.Context, userID int64, alias string) (int64, error) {
+	urlID, err := c.Storage.RemoveUrl(ctx, userID, alias)
+	c.invalidate(urlID, key{userID: userID, alias: alias})
+
+	return urlID, err
+}
+
+func (c *UrlCache) RestoreUrl(ctx context.Context, userID int64, alias string) (entities.URL, error) {
+	url, err := c.Storage.RestoreUrl(ctx, userID, alias)
+	c.invalidate(url.ID, key{userID: userID, alias: alias})
+
+	return url, err
+}
+
+func (c *UrlCache) RestoreUrlByID(ctx context.Context, userID, urlID int64) (entities.URL, error) {
+	url, err := c.Storage.RestoreUrlByID(ctx, userID, urlID)
+	if err == nil {
+		c.invalidate(urlID, key{userID: userID, alias: url.Alias})
+	}
+
+	return url, err
+}
+
+// invalidate evicts entries of the url and of the aliases of the user,
+// among urls of the user and among urls of all users
+func (c *UrlCache) invalidate(urlID int64, keys ...key) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+
+	// when aliases are unique per user, an alias of the url may have been
+	// ambiguous and cached as not found among urls of all users
+	for k := range c.byID[urlID] {
+		keys = append(keys, k)
+	}
+	for _, k := range keys {
+		if k.alias == "" {
+			continue
+		}
+		c.remove(k)
+		c.remove(key{public: true, alias: k.alias})
+	}
+}
+
+// get returns live entry of the key and marks it as recently used
+func (c *UrlCache) get(k key) (*entry, bool) {
+	elem, ok := c.entries[k]
+	if !ok {
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if !c.now().Before(e.expiresAt) {
+		c.remove(k)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+
+	return e, true
+}
+
+func (c *UrlCache) put(k key, url entities.URL, found bool) {
+	ttl := c.ttl
+	if !found {
+		ttl = c.negativeTTL
+	}
+
+	// expired urls are not found, so the entry must not outlive the url
+	now := c.now()
+	expiresAt := now.Add(ttl)
+	if found && !url.ExpiresAt.IsZero() && url.ExpiresAt.Before(expiresAt) {
+		expiresAt = url.ExpiresAt
+	}
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	c.remove(k)
+
+	e := &entry{key: k, url: url, found: found, expiresAt: expiresAt}
+	c.entries[k] = c.lru.PushFront(e)
+	if found {
+		if c.byID[url.ID] == nil {
+			c.byID[url.ID] = make(map[key]struct{})
+		}
+		c.byID[url.ID][k] = struct{}{}
+	}
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back().Value.(*entry).key)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *UrlCache) remove(k key) {
+	elem, ok := c.entries[k]
+	if !ok {
+		return
+	}
+
+	e := c.lru.Remove(elem).(*entry)
+	delete(c.entries, k)
+	if e.found {
+		delete(c.byID[e.url.ID], k)
+		if len(c.byID[e.url.ID]) == 0 {
+			delete(c.byID, e.url.ID)
+		}
+	}
+}
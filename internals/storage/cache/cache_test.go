@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/storage"
+	"github.com/nhassl3/url-saver/internals/storage/memory"
+	"github.com/nhassl3/url-saver/internals/storage/storagetest"
+)
+
+const userID = 1
+
+// TestConformance checks the cache doesn't change behavior of the storage
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, globalAliases bool) storagetest.Storage {
+		return NewUrlCache(memory.NewStorage(globalAliases), 100, time.Minute, time.Minute)
+	})
+}
+
+// newCache returns cache over memory storage with the clock moved by tests
+func newCache(t *testing.T, size int) (*UrlCache, *memory.Storage, *time.Time) {
+	t.Helper()
+
+	s := memory.NewStorage(true)
+	c := NewUrlCache(s, size, time.Minute, 10*time.Second)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	return c, s, &now
+}
+
+func save(t *testing.T, s Storage, alias string) int64 {
+	t.Helper()
+
+	urlID, err := s.SaveUrl(context.Background(), userID, "https://example.com/"+alias, alias, entities.UrlMeta{})
+	if err != nil {
+		t.Fatalf("SaveUrl(%q) error = %v", alias, err)
+	}
+
+	return urlID
+}
+
+func wantStats(t *testing.T, c *UrlCache, want Stats) {
+	t.Helper()
+
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHitsAndMisses(t *testing.T) {
+	ctx := context.Background()
+	c, s, _ := newCache(t, 10)
+	urlID := save(t, s, "cached")
+
+	for range 3 {
+		url, err := c.Url(ctx, userID, "cached")
+		if err != nil || url.ID != urlID {
+			t.Fatalf("Url() = %+v, %v, want url %d", url, err, urlID)
+		}
+	}
+	for range 2 {
+		if _, err := c.PublicUrl(ctx, "cached"); err != nil {
+			t.Fatalf("PublicUrl() error = %v", err)
+		}
+	}
+
+	// the user and the public lookups are cached apart
+	wantStats(t, c, Stats{Hits: 3, Misses: 2, Size: 2})
+}
+
+func TestNegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	c, s, now := newCache(t, 10)
+
+	for range 2 {
+		_, err := c.PublicUrl(ctx, "unknown")
+		if !errors.Is(err, storage.ErrAliasNotFound) {
+			t.Fatalf("PublicUrl() error = %v, want %v", err, storage.ErrAliasNotFound)
+		}
+	}
+	wantStats(t, c, Stats{Hits: 1, NegativeHits: 1, Misses: 1, Size: 1})
+
+	// saved around the cache, the alias is not found until the entry expires
+	save(t, s, "unknown")
+	if _, err := c.PublicUrl(ctx, "unknown"); !errors.Is(err, storage.ErrAliasNotFound) {
+		t.Errorf("PublicUrl() error = %v, want cached %v", err, storage.ErrAliasNotFound)
+	}
+
+	*now = now.Add(10 * time.Second)
+	if _, err := c.PublicUrl(ctx, "unknown"); err != nil {
+		t.Errorf("PublicUrl() after negative ttl error = %v", err)
+	}
+
+	// saved through the cache, the alias is found at once
+	if _, err := c.Url(ctx, userID, "new"); !errors.Is(err, storage.ErrAliasNotFound) {
+		t.Fatalf("Url() error = %v, want %v", err, storage.ErrAliasNotFound)
+	}
+	save(t, c, "new")
+	if _, err := c.Url(ctx, userID, "new"); err != nil {
+		t.Errorf("Url() after save error = %v", err)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+	c, s, now := newCache(t, 10)
+	urlID := save(t, s, "old")
+
+	if _, err := c.PublicUrl(ctx, "old"); err != nil {
+		t.Fatalf("PublicUrl() error = %v", err)
+	}
+
+	// changed around the cache, the url is served from it until it expires
+	if err := s.UpdateUrl(ctx, userID, urlID, "https://example.org", "", entities.UrlMeta{}); err != nil {
+		t.Fatalf("UpdateUrl() error = %v", err)
+	}
+
+	*now = now.Add(time.Minute - time.Second)
+	if url, err := c.PublicUrl(ctx, "old"); err != nil || url.URL != "https://example.com/old" {
+		t.Errorf("PublicUrl() = %+v, %v, want the cached url", url, err)
+	}
+
+	*now = now.Add(time.Second)
+	if url, err := c.PublicUrl(ctx, "old"); err != nil || url.URL != "https://example.org" {
+		t.Errorf("PublicUrl() after ttl = %+v, %v, want the updated url", url, err)
+	}
+	wantStats(t, c, Stats{Hits: 1, Misses: 2, Size: 1})
+}
+
+func TestUrlExpiration(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		// hitAt is the last moment the url is served from the cache
+		hitAt time.Duration
+		// cached is whether the url read again after hitAt is cached
+		cached bool
+	}{
+		{name: "expires before ttl", expiresIn: 20 * time.Second, hitAt: 20*time.Second - time.Millisecond},
+		{name: "expires after ttl", expiresIn: time.Hour, hitAt: time.Minute - time.Millisecond, cached: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, s, now := newCache(t, 10)
+			start := *now
+
+			expiresAt := start.Add(tt.expiresIn)
+			if _, err := s.SaveUrl(ctx, userID, "https://example.com", "expiring", entities.UrlMeta{ExpiresAt: &expiresAt}); err != nil {
+				t.Fatalf("SaveUrl() error = %v", err)
+			}
+
+			for _, at := range []time.Duration{0, tt.hitAt} {
+				*now = start.Add(at)
+				if _, err := c.PublicUrl(ctx, "expiring"); err != nil {
+					t.Fatalf("PublicUrl() at %v error = %v", at, err)
+				}
+			}
+			wantStats(t, c, Stats{Hits: 1, Misses: 1, Size: 1})
+
+			// the storage clock is not moved, so the url is found again, but
+			// once it expired by the clock of the cache it is not cached
+			*now = start.Add(tt.hitAt + time.Millisecond)
+			if _, err := c.PublicUrl(ctx, "expiring"); err != nil {
+				t.Fatalf("PublicUrl() error = %v", err)
+			}
+			want := Stats{Hits: 1, Misses: 2}
+			if tt.cached {
+				want.Size = 1
+			}
+			wantStats(t, c, want)
+		})
+	}
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	c, s, _ := newCache(t, 2)
+	for _, alias := range []string{"a", "b", "c"} {
+		save(t, s, alias)
+	}
+
+	// b is the least recently used when c comes
+	for _, alias := range []string{"a", "b", "a", "c", "a", "b"} {
+		if _, err := c.PublicUrl(ctx, alias); err != nil {
+			t.Fatalf("PublicUrl(%q) error = %v", alias, err)
+		}
+	}
+
+	// misses are a, b, c and b again, which pushed c out
+	wantStats(t, c, Stats{Hits: 2, Misses: 4, Evictions: 2, Size: 2})
+}
+
+func TestInvalidation(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		write func(t *testing.T, c *UrlCache, urlID int64) error
+		// want are aliases found after the write, gone are not found
+		want []string
+		gone []string
+	}{
+		{
+			name: "update url",
+			write: func(t *testing.T, c *UrlCache, urlID int64) error {
+				return c.UpdateUrl(ctx, userID, urlID, "https://example.org/cached", "", entities.UrlMeta{})
+			},
+			want: []string{"cached"},
+		},
+		{
+			name: "update alias",
+			write: func(t *testing.T, c *UrlCache, urlID int64) error {
+				return c.UpdateUrl(ctx, userID, urlID, "", "renamed", entities.UrlMeta{})
+			},
+			want: []string{"renamed"},
+			gone: []string{"cached"},
+		},
+		{
+			name: "remove",
+			write: func(t *testing.T, c *UrlCache, urlID int64) error {
+				_, err := c.RemoveUrl(ctx, userID, "cached")
+				return err
+			},
+			gone: []string{"cached"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, s, _ := newCache(t, 10)
+			urlID := save(t, s, "cached")
+
+			// fill the cache with the url and with the unknown new alias
+			for _, alias := range []string{"cached", "renamed"} {
+				c.Url(ctx, userID, alias)
+				c.PublicUrl(ctx, alias)
+			}
+
+			if err := tt.write(t, c, urlID); err != nil {
+				t.Fatalf("write error = %v", err)
+			}
+
+			for _, alias := range tt.want {
+				want, err := s.Url(ctx, userID, alias)
+				if err != nil {
+					t.Fatalf("Url() of storage error = %v", err)
+				}
+				if url, err := c.Url(ctx, userID, alias); err != nil || url != want {
+					t.Errorf("Url(%q) = %+v, %v, want %+v", alias, url, err, want)
+				}
+				if url, err := c.PublicUrl(ctx, alias); err != nil || url != want {
+					t.Errorf("PublicUrl(%q) = %+v, %v, want %+v", alias, url, err, want)
+				}
+			}
+			for _, alias := range tt.gone {
+				if _, err := c.Url(ctx, userID, alias); !errors.Is(err, storage.ErrAliasNotFound) {
+					t.Errorf("Url(%q) error = %v, want %v", alias, err, storage.ErrAliasNotFound)
+				}
+				if _, err := c.PublicUrl(ctx, alias); !errors.Is(err, storage.ErrAliasNotFound) {
+					t.Errorf("PublicUrl(%q) error = %v, want %v", alias, err, storage.ErrAliasNotFound)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/storage"
+)
+
+const (
+	opSaveApiKey   = "memory.SaveApiKey"
+	opApiKey       = "memory.ApiKey"
+	opApiKeys      = "memory.ApiKeys"
+	opRevokeApiKey = "memory.RevokeApiKey"
+)
+
+var errKeyHashExists = errors.New("api key hash exists")
+
+type apiKey struct {
+	entities.ApiKey
+	keyHash string
+}
+
+func (s *Storage) SaveApiKey(ctx context.Context, userID int64, name, prefix, keyHash string) (keyID int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, sl.Wrap(opSaveApiKey, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.apiKeys {
+		if key.keyHash == keyHash {
+			return 0, sl.Wrap(opSaveApiKey, errKeyHashExists)
+		}
+	}
+
+	s.lastKeyID++
+	s.apiKeys = append(s.apiKeys, &apiKey{
+		ApiKey: entities.ApiKey{
+			ID:        s.lastKeyID,
+			UserID:    userID,
+			Name:      name,
+			Prefix:    prefix,
+			CreatedAt: now(),
+		},
+		keyHash: keyHash,
+	})
+
+	return s.lastKeyID, nil
+}
+
+// ApiKey returns the key with given hash, including revoked ones
+func (s *Storage) ApiKey(ctx context.Context, keyHash string) (entities.ApiKey, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.ApiKey{}, sl.Wrap(opApiKey, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.keyHash == keyHash {
+			return key.copy(), nil
+		}
+	}
+
+	return entities.ApiKey{}, sl.Wrap(opApiKey, storage.ErrApiKeyNotFound)
+}
+
+func (s *Storage) ApiKeys(ctx context.Context, userID int64) (keys []entities.ApiKey, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, sl.Wrap(opApiKeys, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// keys are appended in order of their IDs
+	for _, key := range s.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key.copy())
+		}
+	}
+
+	return
+}
+
+// RevokeApiKey marks the key of the user as revoked. Revoking already
+// revoked key is not an error
+func (s *Storage) RevokeApiKey(ctx context.Context, userID, keyID int64) (err error) {
+	if err = ctx.Err(); err != nil {
+		return sl.Wrap(opRevokeApiKey, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.apiKeys {
+		if key.UserID == userID && key.ID == keyID {
+			if key.RevokedAt == nil {
+				revokedAt := now()
+				key.RevokedAt = &revokedAt
+			}
+			return nil
+		}
+	}
+
+	return sl.Wrap(opRevokeApiKey, storage.ErrApiKeyNotFound)
+}
+
+// copy returns the key which doesn't share revocation time with the stored one
+func (k *apiKey) copy() entities.ApiKey {
+	key := k.ApiKey
+	if key.RevokedAt != nil {
+		revokedAt := *key.RevokedAt
+		key.RevokedAt = &revokedAt
+	}
+
+	return key
+}
@@ -0,0 +1,439 @@
+package memory
+
+import (
+	"context"
+	neturl "net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/storage"
+)
+
+const (
+	opSaveUrl    = "memory.SaveUrl"
+	opUrl        = "memory.Url"
+	opUrlByID    = "memory.UrlByID"
+	opUrlByURL   = "memory.UrlByURL"
+	opPublicUrl  = "memory.PublicUrl"
+	opUrlList    = "memory.UrlList"
+	opUpdateUrl  = "memory.UpdateUrl"
+	opRemoveUrl  = "memory.RemoveUrl"
+	opRestoreUrl = "memory.RestoreUrl"
+)
+
+// url is the stored url with the fields which are not returned
+type url struct {
+	entities.URL
+	userID    int64
+	domain    string
+	tags      map[string]struct{}
+	deletedAt time.Time
+}
+
+// active reports whether the url is neither removed nor expired,
+// others are treated as not found
+func (u *url) active(now time.Time) bool {
+	return u.deletedAt.IsZero() && !u.expired(now)
+}
+
+func (u *url) expired(now time.Time) bool {
+	return !u.ExpiresAt.IsZero() && !u.ExpiresAt.After(now)
+}
+
+// Storage keeps everything in memory, it is lost on restart. It behaves
+// like the sqlite storage and is safe for concurrent use
+type Storage struct {
+	mu sync.RWMutex
+	// globalAliases makes aliases unique across all users,
+	// otherwise they are unique only within urls of one user
+	globalAliases bool
+
+	urls      map[int64]*url
+	lastUrlID int64
+	// archive keeps purged expired urls like urls_archive does
+	archive map[int64]url
+
+	apiKeys   []*apiKey
+	lastKeyID int64
+
+	rollups map[int64]map[time.Time]*hourVisits
+}
+
+func NewStorage(globalAliases bool) *Storage {
+	return &Storage{
+		globalAliases: globalAliases,
+		urls:          make(map[int64]*url),
+		archive:       make(map[int64]url),
+		rollups:       make(map[int64]map[time.Time]*hourVisits),
+	}
+}
+
+// now returns current time the way it is stored
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func (s *Storage) SaveUrl(ctx context.Context, userID int64, targetURL, alias string, meta entities.UrlMeta) (urlID int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, sl.Wrap(opSaveUrl, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.aliasTaken(userID, alias, 0) {
+		return 0, sl.Wrap(opSaveUrl, storage.ErrAliasExists)
+	}
+
+	s.lastUrlID++
+	createdAt := now()
+	u := &url{
+		URL: entities.URL{
+			ID:        s.lastUrlID,
+			URL:       targetURL,
+			Alias:     alias,
+			Title:     meta.Title,
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+			ExpiresAt: expiresAt(meta.ExpiresAt),
+		},
+		userID: userID,
+		domain: domainOf(targetURL),
+		tags:   tagSet(meta.Tags),
+	}
+	s.urls[u.ID] = u
+
+	return u.ID, nil
+}
+
+func (s *Storage) Url(ctx context.Context, userID int64, alias string) (entities.URL, error) {
+	return s.find(ctx, opUrl, func(u *url) bool { return u.userID == userID && u.Alias == alias })
+}
+
+func (s *Storage) UrlByID(ctx context.Context, userID, urlID int64) (entities.URL, error) {
+	return s.find(ctx, opUrlByID, func(u *url) bool { return u.userID == userID && u.ID == urlID })
+}
+
+// UrlByURL returns the oldest url of the user with the same target url
+func (s *Storage) UrlByURL(ctx context.Context, userID int64, targetURL string) (entities.URL, error) {
+	return s.find(ctx, opUrlByURL, func(u *url) bool { return u.userID == userID && u.URL.URL == targetURL })
+}
+
+// find returns the active url with the lowest ID matching the condition
+func (s *Storage) find(ctx context.Context, op string, match func(u *url) bool) (entities.URL, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.URL{}, sl.Wrap(op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *url
+	now := now()
+	for _, u := range s.urls {
+		if u.active(now) && match(u) && (found == nil || u.ID < found.ID) {
+			found = u
+		}
+	}
+	if found == nil {
+		return entities.URL{}, sl.Wrap(op, storage.ErrAliasNotFound)
+	}
+
+	return found.URL, nil
+}
+
+// PublicUrl returns url by alias among urls of all users. When aliases are
+// unique only per user and several users have the alias, it is not found
+func (s *Storage) PublicUrl(ctx context.Context, alias string) (entities.URL, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.URL{}, sl.Wrap(opPublicUrl, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found []*url
+	now := now()
+	for _, u := range s.urls {
+		if u.Alias == alias && u.active(now) {
+			found = append(found, u)
+		}
+	}
+	if len(found) != 1 {
+		return entities.URL{}, sl.Wrap(opPublicUrl, storage.ErrAliasNotFound)
+	}
+
+	return found[0].URL, nil
+}
+
+// UrlList returns up to limit urls of the user matching the filter in its
+// order. The list starts after the cursor, nil cursor means the beginning
+func (s *Storage) UrlList(
+	ctx context.Context,
+	userID int64,
+	filter entities.UrlFilter,
+	after *entities.Cursor,
+	limit int,
+) (urls []entities.URL, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, sl.Wrap(opUrlList, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	compare := listOrder(filter)
+	now := now()
+	for _, u := range s.urls {
+		if u.userID != userID || !u.active(now) || !matches(u, filter) {
+			continue
+		}
+		if after != nil && compare(u.URL, cursorUrl(after)) <= 0 {
+			continue
+		}
+		urls = append(urls, u.URL)
+	}
+
+	slices.SortFunc(urls, compare)
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+
+	return
+}
+
+// UpdateUrl sets new url, alias and meta for the url of the user with given
+// ID. Empty values keep the current ones
+func (s *Storage) UpdateUrl(ctx context.Context, userID, urlID int64, targetURL, alias string, meta entities.UrlMeta) (err error) {
+	if err = ctx.Err(); err != nil {
+		return sl.Wrap(opUpdateUrl, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.urls[urlID]
+	if !ok || u.userID != userID || !u.active(now()) {
+		return sl.Wrap(opUpdateUrl, storage.ErrAliasNotFound)
+	}
+	if alias != "" && s.aliasTaken(userID, alias, urlID) {
+		return sl.Wrap(opUpdateUrl, storage.ErrAliasExists)
+	}
+
+	if targetURL != "" {
+		u.URL.URL = targetURL
+		u.domain = domainOf(targetURL)
+	}
+	if alias != "" {
+		u.Alias = alias
+	}
+	if meta.Title != "" {
+		u.Title = meta.Title
+	}
+	if meta.ExpiresAt != nil {
+		u.ExpiresAt = expiresAt(meta.ExpiresAt)
+	}
+	if meta.Tags != nil {
+		u.tags = tagSet(meta.Tags)
+	}
+	u.UpdatedAt = now()
+
+	return nil
+}
+
+// RemoveUrl moves the url of the user with given alias to the trash and
+// returns its ID. The alias stays taken until the url is purged
+func (s *Storage) RemoveUrl(ctx context.Context, userID int64, alias string) (urlID int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, sl.Wrap(opRemoveUrl, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := now()
+	for _, u := range s.urls {
+		if u.userID == userID && u.Alias == alias && u.active(now) {
+			u.deletedAt = now
+			return u.ID, nil
+		}
+	}
+
+	return 0, sl.Wrap(opRemoveUrl, storage.ErrAliasNotFound)
+}
+
+// RestoreUrl takes the url of the user with given alias out of the trash.
+// Expired urls are not restored
+func (s *Storage) RestoreUrl(ctx context.Context, userID int64, alias string) (entities.URL, error) {
+	return s.restoreUrl(ctx, func(u *url) bool { return u.userID == userID && u.Alias == alias })
+}
+
+func (s *Storage) RestoreUrlByID(ctx context.Context, userID, urlID int64) (entities.URL, error) {
+	return s.restoreUrl(ctx, func(u *url) bool { return u.userID == userID && u.ID == urlID })
+}
+
+func (s *Storage) restoreUrl(ctx context.Context, match func(u *url) bool) (entities.URL, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.URL{}, sl.Wrap(opRestoreUrl, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := now()
+	for _, u := range s.urls {
+		if !u.deletedAt.IsZero() && !u.expired(now) && match(u) {
+			u.deletedAt = time.Time{}
+			u.UpdatedAt = now
+			return u.URL, nil
+		}
+	}
+
+	return entities.URL{}, sl.Wrap(opRestoreUrl, storage.ErrAliasNotFound)
+}
+
+// aliasTaken reports whether the alias belongs to another url, removed and
+// expired urls keep their aliases until they are purged
+func (s *Storage) aliasTaken(userID int64, alias string, exceptID int64) bool {
+	for _, u := range s.urls {
+		if u.ID != exceptID && u.Alias == alias && (s.globalAliases || u.userID == userID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listOrder compares urls in the order of the list, ID breaks ties
+func listOrder(filter entities.UrlFilter) func(a, b entities.URL) int {
+	key := func(a, b entities.URL) int { return a.CreatedAt.Compare(b.CreatedAt) }
+	switch filter.SortBy {
+	case entities.SortByUpdatedAt:
+		key = func(a, b entities.URL) int { return a.UpdatedAt.Compare(b.UpdatedAt) }
+	case entities.SortByAlias:
+		key = func(a, b entities.URL) int { return strings.Compare(a.Alias, b.Alias) }
+	}
+
+	return func(a, b entities.URL) int {
+		order := key(a, b)
+		if order == 0 {
+			order = compareInts(a.ID, b.ID)
+		}
+		if filter.Descending {
+			return -order
+		}
+		return order
+	}
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// cursorUrl returns url with the fields of the cursor to compare urls with
+func cursorUrl(after *entities.Cursor) entities.URL {
+	return entities.URL{
+		ID:        after.ID,
+		Alias:     after.Alias,
+		CreatedAt: after.CreatedAt,
+		UpdatedAt: after.UpdatedAt,
+	}
+}
+
+func matches(u *url, filter entities.UrlFilter) bool {
+	if filter.Domain != "" && u.domain != strings.ToLower(filter.Domain) {
+		return false
+	}
+	if !strings.HasPrefix(u.Alias, filter.AliasPrefix) {
+		return false
+	}
+	if _, ok := u.tags[filter.Tag]; filter.Tag != "" && !ok {
+		return false
+	}
+	if !matchesQuery(u.URL.URL+" "+u.Title, filter.Query) {
+		return false
+	}
+	if !filter.CreatedFrom.IsZero() && u.CreatedAt.Before(filter.CreatedFrom) {
+		return false
+	}
+	if !filter.CreatedTo.IsZero() && !u.CreatedAt.Before(filter.CreatedTo) {
+		return false
+	}
+	if !filter.UpdatedFrom.IsZero() && u.UpdatedAt.Before(filter.UpdatedFrom) {
+		return false
+	}
+	if !filter.UpdatedTo.IsZero() && !u.UpdatedAt.Before(filter.UpdatedTo) {
+		return false
+	}
+
+	return true
+}
+
+// matchesQuery reports whether the text contains every word of the query.
+// Like in full text search of sqlite, words are split into letters and
+// digits and a word made of several of them must match them in a row
+func matchesQuery(text, query string) bool {
+	tokens := tokenize(text)
+	for _, word := range strings.Fields(query) {
+		if phrase := tokenize(word); len(phrase) > 0 && !containsPhrase(tokens, phrase) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func containsPhrase(tokens, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(tokens); i++ {
+		if slices.Equal(tokens[i:i+len(phrase)], phrase) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func tagSet(tags []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		set[tag] = struct{}{}
+	}
+
+	return set
+}
+
+// expiresAt returns stored expiration time, zero for nil or zero time
+func expiresAt(t *time.Time) time.Time {
+	if t == nil || t.IsZero() {
+		return time.Time{}
+	}
+
+	return t.UTC()
+}
+
+// domainOf returns lowercased host of the url, empty for invalid urls
+func domainOf(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/nhassl3/url-saver/internals/storage/memory"
+	"github.com/nhassl3/url-saver/internals/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, globalAliases bool) storagetest.Storage {
+		return memory.NewStorage(globalAliases)
+	})
+}
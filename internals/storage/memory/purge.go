@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const (
+	opPurgeExpiredUrls = "memory.PurgeExpiredUrls"
+	opPurgeDeletedUrls = "memory.PurgeDeletedUrls"
+)
+
+// PurgeExpiredUrls deletes up to limit expired urls and returns how many were
+// deleted. With archive they are copied to the archive first
+func (s *Storage) PurgeExpiredUrls(ctx context.Context, archive bool, limit int) (purged int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, sl.Wrap(opPurgeExpiredUrls, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := now()
+	expired := s.oldest(limit,
+		func(u *url) bool { return u.expired(now) },
+		func(u *url) time.Time { return u.ExpiresAt },
+	)
+	for _, u := range expired {
+		if archive {
+			s.archive[u.ID] = *u
+		}
+		delete(s.urls, u.ID)
+	}
+
+	return int64(len(expired)), nil
+}
+
+// PurgeDeletedUrls deletes up to limit urls removed to the trash before the
+// given time and returns how many were deleted. Their aliases become free
+func (s *Storage) PurgeDeletedUrls(ctx context.Context, before time.Time, limit int) (purged int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, sl.Wrap(opPurgeDeletedUrls, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := s.oldest(limit,
+		func(u *url) bool { return !u.deletedAt.IsZero() && !u.deletedAt.After(before) },
+		func(u *url) time.Time { return u.deletedAt },
+	)
+	for _, u := range deleted {
+		delete(s.urls, u.ID)
+	}
+
+	return int64(len(deleted)), nil
+}
+
+// oldest returns up to limit urls matching the condition ordered by the time
+func (s *Storage) oldest(limit int, match func(u *url) bool, at func(u *url) time.Time) []*url {
+	var urls []*url
+	for _, u := range s.urls {
+		if match(u) {
+			urls = append(urls, u)
+		}
+	}
+
+	slices.SortFunc(urls, func(a, b *url) int { return at(a).Compare(at(b)) })
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+
+	return urls
+}
@@ -0,0 +1,249 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const (
+	opSaveVisits    = "memory.SaveVisits"
+	opVisitStats    = "memory.VisitStats"
+	opVisitSeries   = "memory.VisitSeries"
+	opTopReferrers  = "memory.TopReferrers"
+	opTopUserAgents = "memory.TopUserAgents"
+	opTopUrls       = "memory.TopUrls"
+)
+
+// hourVisits is the hourly rollup of visits of one url. Visits themselves
+// are not kept, so memory grows with hours instead of visits
+type hourVisits struct {
+	visits     int64
+	visitors   map[string]struct{}
+	referrers  map[string]int64
+	userAgents map[string]int64
+}
+
+// bucketStart truncates the hour of rollups to the start of its bucket in
+// UTC, weeks start on Monday
+var bucketStart = map[entities.Bucket]func(hour time.Time) time.Time{
+	entities.BucketHour: func(hour time.Time) time.Time { return hour },
+	entities.BucketDay:  startOfDay,
+	entities.BucketWeek: func(hour time.Time) time.Time {
+		day := startOfDay(hour)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	},
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// SaveVisits adds the batch of visits to the hourly rollups
+func (s *Storage) SaveVisits(ctx context.Context, visits []entities.Visit) error {
+	if err := ctx.Err(); err != nil {
+		return sl.Wrap(opSaveVisits, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, visit := range visits {
+		hours, ok := s.rollups[visit.URLID]
+		if !ok {
+			hours = make(map[time.Time]*hourVisits)
+			s.rollups[visit.URLID] = hours
+		}
+
+		hour := visit.VisitedAt.UTC().Truncate(time.Hour)
+		rollup, ok := hours[hour]
+		if !ok {
+			rollup = &hourVisits{
+				visitors:   make(map[string]struct{}),
+				referrers:  make(map[string]int64),
+				userAgents: make(map[string]int64),
+			}
+			hours[hour] = rollup
+		}
+
+		rollup.visits++
+		if visit.IPHash != "" {
+			rollup.visitors[visit.IPHash] = struct{}{}
+		}
+		rollup.referrers[visit.Referrer]++
+		rollup.userAgents[visit.UserAgent]++
+	}
+
+	return nil
+}
+
+// VisitStats counts visits and distinct visitors of the url in the hours
+// starting in [from, to)
+func (s *Storage) VisitStats(ctx context.Context, urlID int64, from, to time.Time) (stats entities.VisitStats, err error) {
+	if err = ctx.Err(); err != nil {
+		return entities.VisitStats{}, sl.Wrap(opVisitStats, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	visitors := make(map[string]struct{})
+	s.eachHour(urlID, from, to, func(_ time.Time, rollup *hourVisits) {
+		stats.Visits += rollup.visits
+		for visitor := range rollup.visitors {
+			visitors[visitor] = struct{}{}
+		}
+	})
+	stats.Visitors = int64(len(visitors))
+
+	return
+}
+
+// VisitSeries counts visits and distinct visitors of the url in the hours
+// starting in [from, to) per bucket. Buckets without visits are omitted
+func (s *Storage) VisitSeries(
+	ctx context.Context,
+	urlID int64,
+	bucket entities.Bucket,
+	from, to time.Time,
+) (series []entities.VisitCount, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, sl.Wrap(opVisitSeries, err)
+	}
+
+	start, ok := bucketStart[bucket]
+	if !ok {
+		return nil, sl.Wrap(opVisitSeries, fmt.Errorf("unknown bucket %q", bucket))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[time.Time]*entities.VisitCount)
+	visitors := make(map[time.Time]map[string]struct{})
+	s.eachHour(urlID, from, to, func(hour time.Time, rollup *hourVisits) {
+		startAt := start(hour)
+		count, ok := counts[startAt]
+		if !ok {
+			count = &entities.VisitCount{Start: startAt}
+			counts[startAt] = count
+			visitors[startAt] = make(map[string]struct{})
+		}
+
+		count.Visits += rollup.visits
+		for visitor := range rollup.visitors {
+			visitors[startAt][visitor] = struct{}{}
+		}
+	})
+
+	for startAt, count := range counts {
+		count.Visitors = int64(len(visitors[startAt]))
+		series = append(series, *count)
+	}
+	slices.SortFunc(series, func(a, b entities.VisitCount) int { return a.Start.Compare(b.Start) })
+
+	return
+}
+
+// TopReferrers returns up to limit referrers of the url with most visits in
+// the hours starting in [from, to). Empty referrer stands for direct visits
+func (s *Storage) TopReferrers(ctx context.Context, urlID int64, from, to time.Time, limit int) ([]entities.TopValue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, sl.Wrap(opTopReferrers, err)
+	}
+
+	return s.topValues(urlID, from, to, limit, func(rollup *hourVisits) map[string]int64 { return rollup.referrers }), nil
+}
+
+// TopUserAgents returns up to limit user agents of the url with most visits
+// in the hours starting in [from, to)
+func (s *Storage) TopUserAgents(ctx context.Context, urlID int64, from, to time.Time, limit int) ([]entities.TopValue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, sl.Wrap(opTopUserAgents, err)
+	}
+
+	return s.topValues(urlID, from, to, limit, func(rollup *hourVisits) map[string]int64 { return rollup.userAgents }), nil
+}
+
+// topValues sums visits of the rollups by the values they are counted by
+func (s *Storage) topValues(
+	urlID int64,
+	from, to time.Time,
+	limit int,
+	values func(rollup *hourVisits) map[string]int64,
+) (top []entities.TopValue) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	totals := make(map[string]int64)
+	s.eachHour(urlID, from, to, func(_ time.Time, rollup *hourVisits) {
+		for value, visits := range values(rollup) {
+			totals[value] += visits
+		}
+	})
+
+	for value, visits := range totals {
+		top = append(top, entities.TopValue{Value: value, Visits: visits})
+	}
+	slices.SortFunc(top, func(a, b entities.TopValue) int {
+		if a.Visits != b.Visits {
+			return compareInts(b.Visits, a.Visits)
+		}
+		return strings.Compare(a.Value, b.Value)
+	})
+	if len(top) > limit {
+		top = top[:limit]
+	}
+
+	return
+}
+
+// TopUrls returns up to limit urls of the user with most visits in the hours
+// starting in [from, to). Removed and expired urls are skipped
+func (s *Storage) TopUrls(ctx context.Context, userID int64, from, to time.Time, limit int) (top []entities.UrlVisits, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, sl.Wrap(opTopUrls, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := now()
+	for _, u := range s.urls {
+		if u.userID != userID || !u.active(now) {
+			continue
+		}
+
+		var visits int64
+		s.eachHour(u.ID, from, to, func(_ time.Time, rollup *hourVisits) { visits += rollup.visits })
+		if visits > 0 {
+			top = append(top, entities.UrlVisits{URLID: u.ID, URL: u.URL.URL, Alias: u.Alias, Visits: visits})
+		}
+	}
+
+	slices.SortFunc(top, func(a, b entities.UrlVisits) int {
+		if a.Visits != b.Visits {
+			return compareInts(b.Visits, a.Visits)
+		}
+		return compareInts(a.URLID, b.URLID)
+	})
+	if len(top) > limit {
+		top = top[:limit]
+	}
+
+	return
+}
+
+// eachHour calls fn for rollups of the url in the hours starting in [from, to)
+func (s *Storage) eachHour(urlID int64, from, to time.Time, fn func(hour time.Time, rollup *hourVisits)) {
+	for hour, rollup := range s.rollups[urlID] {
+		if !hour.Before(from) && hour.Before(to) {
+			fn(hour, rollup)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+	"github.com/nhassl3/url-saver/internals/storage"
+)
+
+const (
+	opSaveApiKey   = "postgres.SaveApiKey"
+	opApiKey       = "postgres.ApiKey"
+	opApiKeys      = "postgres.ApiKeys"
+	opRevokeApiKey = "postgres.RevokeApiKey"
+)
+
+func (s *Storage) SaveApiKey(ctx context.Context, userID int64, name, prefix, keyHash string) (keyID int64, err error) {
+	stmt, err := s.db.PrepareContext(ctx,
+		"INSERT INTO api_keys (user_id, name, prefix, key_hash) VALUES ($1, $2, $3, $4) RETURNING id",
+	)
+	if err != nil {
+		return 0, sl.Wrap(opSaveApiKey, err)
+	}
+	defer stmt.Close()
+
+	if err = stmt.QueryRowContext(ctx, userID, name, prefix, keyHash).Scan(&keyID); err != nil {
+		return 0, sl.Wrap(opSaveApiKey, err)
+	}
+
+	return
+}
+
+// ApiKey returns the key with given hash, including revoked ones
+func (s *Storage) ApiKey(ctx context.Context, keyHash string) (key entities.ApiKey, err error) {
+	stmt, err := s.db.PrepareContext(ctx,
+		"SELECT id, user_id, name, prefix, created_at, revoked_at FROM api_keys WHERE key_hash = $1",
+	)
+	if err != nil {
+		return entities.ApiKey{}, sl.Wrap(opApiKey, err)
+	}
+	defer stmt.Close()
+
+	key, err = scanApiKey(stmt.QueryRowContext(ctx, keyHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.ApiKey{}, sl.Wrap(opApiKey, storage.ErrApiKeyNotFound)
+		}
+		return entities.ApiKey{}, sl.Wrap(opApiKey, err)
+	}
+
+	return
+}
+
+func (s *Storage) ApiKeys(ctx context.Context, userID int64) (keys []entities.ApiKey, err error) {
+	stmt, err := s.db.PrepareContext(ctx,
+		"SELECT id, user_id, name, prefix, created_at, revoked_at FROM api_keys WHERE user_id = $1 ORDER BY id",
+	)
+	if err != nil {
+		return nil, sl.Wrap(opApiKeys, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, sl.Wrap(opApiKeys, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, sl.Wrap(opApiKeys, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, sl.Wrap(opApiKeys, err)
+	}
+
+	return
+}
+
+// RevokeApiKey marks the key of the user as revoked. Revoking already
+// revoked key is not an error
+func (s *Storage) RevokeApiKey(ctx context.Context, userID, keyID int64) (err error) {
+	stmt, err := s.db.PrepareContext(ctx,
+		"UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE user_id = $1 AND id = $2",
+	)
+	if err != nil {
+		return sl.Wrap(opRevokeApiKey, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, userID, keyID)
+	if err != nil {
+		return sl.Wrap(opRevokeApiKey, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return sl.Wrap(opRevokeApiKey, err)
+	}
+	if affected == 0 {
+		return sl.Wrap(opRevokeApiKey, storage.ErrApiKeyNotFound)
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanApiKey(row rowScanner) (key entities.ApiKey, err error) {
+	var revokedAt sql.NullTime
+
+	if err = row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.CreatedAt, &revokedAt); err != nil {
+		return entities.ApiKey{}, err
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+)
+
+// listQuery builds query of the urls page. Sort column is taken only from
+// the known fields, every value is passed as an argument
+func listQuery(userID int64, filter entities.UrlFilter, after *entities.Cursor, limit int) (string, []any) {
+	conditions := []string{"user_id = $1", activeUrl}
+	args := []any{userID}
+
+	// arg adds the value and returns its placeholder
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.Domain != "" {
+		conditions = append(conditions, "domain = "+arg(strings.ToLower(filter.Domain)))
+	}
+	if filter.AliasPrefix != "" {
+		conditions = append(conditions, "alias LIKE "+arg(escapeLike(filter.AliasPrefix))+`::TEXT || '%'`)
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, "id IN (SELECT url_id FROM url_tags WHERE tag = "+arg(filter.Tag)+")")
+	}
+	if query := strings.TrimSpace(filter.Query); query != "" {
+		// plainto_tsquery matches all words and ignores operators in the input
+		conditions = append(conditions, "search @@ plainto_tsquery('simple', "+arg(query)+")")
+	}
+	if !filter.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(filter.CreatedFrom.UTC()))
+	}
+	if !filter.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(filter.CreatedTo.UTC()))
+	}
+	if !filter.UpdatedFrom.IsZero() {
+		conditions = append(conditions, "updated_at >= "+arg(filter.UpdatedFrom.UTC()))
+	}
+	if !filter.UpdatedTo.IsZero() {
+		conditions = append(conditions, "updated_at < "+arg(filter.UpdatedTo.UTC()))
+	}
+
+	column := "created_at"
+	switch filter.SortBy {
+	case entities.SortByUpdatedAt:
+		column = "updated_at"
+	case entities.SortByAlias:
+		column = "alias"
+	}
+
+	// aliases are compared byte by byte like in SQLite, not by the collation
+	// of the database
+	key := column
+	if column == "alias" {
+		key = `alias COLLATE "C"`
+	}
+
+	comparison, direction := ">", "ASC"
+	if filter.Descending {
+		comparison, direction = "<", "DESC"
+	}
+
+	if after != nil {
+		var value string
+		switch column {
+		case "updated_at":
+			value = arg(after.UpdatedAt.UTC())
+		case "alias":
+			value = arg(after.Alias)
+		default:
+			value = arg(after.CreatedAt.UTC())
+		}
+		conditions = append(conditions, "("+key+", id) "+comparison+" ("+value+", "+arg(after.ID)+")")
+	}
+
+	query := "SELECT " + urlColumns + " FROM urls" +
+		" WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY " + key + " " + direction + ", id " + direction +
+		" LIMIT " + strconv.Itoa(limit)
+
+	return query, args
+}
+
+// escapeLike escapes LIKE wildcards so the value is matched literally,
+// backslash is the default escape character of postgres
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
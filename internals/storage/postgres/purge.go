@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const (
+	opPurgeExpiredUrls = "postgres.PurgeExpiredUrls"
+	opPurgeDeletedUrls = "postgres.PurgeDeletedUrls"
+)
+
+// PurgeExpiredUrls deletes up to limit expired urls and returns how many were
+// deleted. With archive they are copied to urls_archive in the same statement.
+// Rows locked by another janitor are skipped
+func (s *Storage) PurgeExpiredUrls(ctx context.Context, archive bool, limit int) (purged int64, err error) {
+	const purge = `DELETE FROM urls WHERE id IN (
+			SELECT id FROM urls WHERE expires_at IS NOT NULL AND expires_at <= now()
+			ORDER BY expires_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		) RETURNING id, user_id, url, alias, title, created_at, updated_at, expires_at`
+
+	query := "WITH purged AS (" + purge + ") SELECT COUNT(*) FROM purged"
+	if archive {
+		query = "WITH purged AS (" + purge + `), archived AS (
+			INSERT INTO urls_archive (id, user_id, url, alias, title, created_at, updated_at, expires_at)
+			SELECT * FROM purged
+			ON CONFLICT (id) DO UPDATE SET
+				user_id = excluded.user_id, url = excluded.url, alias = excluded.alias, title = excluded.title,
+				created_at = excluded.created_at, updated_at = excluded.updated_at,
+				expires_at = excluded.expires_at, archived_at = now()
+		) SELECT COUNT(*) FROM purged`
+	}
+
+	if err = s.db.QueryRowContext(ctx, query, limit).Scan(&purged); err != nil {
+		return 0, sl.Wrap(opPurgeExpiredUrls, err)
+	}
+
+	return
+}
+
+// PurgeDeletedUrls deletes up to limit urls removed to the trash before the
+// given time and returns how many were deleted. Their aliases become free
+func (s *Storage) PurgeDeletedUrls(ctx context.Context, before time.Time, limit int) (purged int64, err error) {
+	stmt, err := s.db.PrepareContext(ctx, `DELETE FROM urls WHERE id IN (
+		SELECT id FROM urls WHERE deleted_at IS NOT NULL AND deleted_at <= $1
+		ORDER BY deleted_at LIMIT $2 FOR UPDATE SKIP LOCKED
+	)`)
+	if err != nil {
+		return 0, sl.Wrap(opPurgeDeletedUrls, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, before.UTC(), limit)
+	if err != nil {
+		return 0, sl.Wrap(opPurgeDeletedUrls, err)
+	}
+
+	purged, err = res.RowsAffected()
+	if err != nil {
+		return 0, sl.Wrap(opPurgeDeletedUrls, err)
+	}
+
+	return
+}
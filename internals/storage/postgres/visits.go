@@ -0,0 +1,225 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const (
+	opSaveVisits    = "postgres.SaveVisits"
+	opVisitStats    = "postgres.VisitStats"
+	opVisitSeries   = "postgres.VisitSeries"
+	opTopReferrers  = "postgres.TopReferrers"
+	opTopUserAgents = "postgres.TopUserAgents"
+	opTopUrls       = "postgres.TopUrls"
+)
+
+// bucketStart are expressions truncating the hour of rollups to the start
+// of its bucket in UTC, weeks start on Monday
+var bucketStart = map[entities.Bucket]string{
+	entities.BucketHour: "hour",
+	entities.BucketDay:  "date_trunc('day', hour, 'UTC')",
+	entities.BucketWeek: "date_trunc('week', hour, 'UTC')",
+}
+
+// SaveVisits saves the batch of visits and adds them to the hourly rollups
+// in one statement
+func (s *Storage) SaveVisits(ctx context.Context, visits []entities.Visit) error {
+	// the batch is passed as arrays of columns
+	var (
+		urlIDs                                                         []int64
+		aliases, visitedAt, referrers, userAgents, ipHashes, countries []string
+	)
+	for _, visit := range visits {
+		urlIDs = append(urlIDs, visit.URLID)
+		aliases = append(aliases, visit.Alias)
+		visitedAt = append(visitedAt, visit.VisitedAt.UTC().Format(time.RFC3339Nano))
+		referrers = append(referrers, visit.Referrer)
+		userAgents = append(userAgents, visit.UserAgent)
+		ipHashes = append(ipHashes, visit.IPHash)
+		countries = append(countries, visit.Country)
+	}
+
+	_, err := s.db.ExecContext(ctx, `WITH batch AS (
+			INSERT INTO visits (url_id, alias, visited_at, referrer, user_agent, ip_hash, country)
+			SELECT * FROM unnest(
+				$1::BIGINT[], $2::TEXT[], $3::TIMESTAMPTZ[], $4::TEXT[], $5::TEXT[], $6::TEXT[], $7::TEXT[]
+			)
+			RETURNING url_id, date_trunc('hour', visited_at, 'UTC') AS hour, referrer, user_agent, ip_hash
+		), rollups AS (
+			INSERT INTO visit_rollups_hourly (url_id, hour, visits)
+			SELECT url_id, hour, COUNT(*) FROM batch GROUP BY url_id, hour
+			ON CONFLICT (url_id, hour) DO UPDATE SET visits = visit_rollups_hourly.visits + excluded.visits
+		), visitors AS (
+			INSERT INTO visit_visitors_hourly (url_id, hour, ip_hash)
+			SELECT DISTINCT url_id, hour, ip_hash FROM batch WHERE ip_hash != ''
+			ON CONFLICT DO NOTHING
+		), referrers AS (
+			INSERT INTO visit_referrers_hourly (url_id, hour, referrer, visits)
+			SELECT url_id, hour, referrer, COUNT(*) FROM batch GROUP BY url_id, hour, referrer
+			ON CONFLICT (url_id, hour, referrer) DO UPDATE SET visits = visit_referrers_hourly.visits + excluded.visits
+		)
+		INSERT INTO visit_user_agents_hourly (url_id, hour, user_agent, visits)
+		SELECT url_id, hour, user_agent, COUNT(*) FROM batch GROUP BY url_id, hour, user_agent
+		ON CONFLICT (url_id, hour, user_agent) DO UPDATE SET visits = visit_user_agents_hourly.visits + excluded.visits`,
+		pq.Array(urlIDs), pq.Array(aliases), pq.Array(visitedAt), pq.Array(referrers),
+		pq.Array(userAgents), pq.Array(ipHashes), pq.Array(countries),
+	)
+	if err != nil {
+		return sl.Wrap(opSaveVisits, err)
+	}
+
+	return nil
+}
+
+// VisitStats counts visits and distinct visitors of the url in the hours
+// starting in [from, to)
+func (s *Storage) VisitStats(ctx context.Context, urlID int64, from, to time.Time) (stats entities.VisitStats, err error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT
+		(SELECT COALESCE(SUM(visits), 0) FROM visit_rollups_hourly WHERE url_id = $1 AND hour >= $2 AND hour < $3),
+		(SELECT COUNT(DISTINCT ip_hash) FROM visit_visitors_hourly WHERE url_id = $1 AND hour >= $2 AND hour < $3)`)
+	if err != nil {
+		return entities.VisitStats{}, sl.Wrap(opVisitStats, err)
+	}
+	defer stmt.Close()
+
+	if err = stmt.QueryRowContext(ctx, urlID, from.UTC(), to.UTC()).Scan(&stats.Visits, &stats.Visitors); err != nil {
+		return entities.VisitStats{}, sl.Wrap(opVisitStats, err)
+	}
+
+	return
+}
+
+// VisitSeries counts visits and distinct visitors of the url in the hours
+// starting in [from, to) per bucket. Buckets without visits are omitted
+func (s *Storage) VisitSeries(
+	ctx context.Context,
+	urlID int64,
+	bucket entities.Bucket,
+	from, to time.Time,
+) (series []entities.VisitCount, err error) {
+	start, ok := bucketStart[bucket]
+	if !ok {
+		return nil, sl.Wrap(opVisitSeries, fmt.Errorf("unknown bucket %q", bucket))
+	}
+
+	// visitors of a bucket are counted apart, they may come back in many hours of it
+	rows, err := s.db.QueryContext(ctx, `SELECT visits.bucket, visits.total, COALESCE(visitors.total, 0)
+		FROM (
+			SELECT `+start+` AS bucket, SUM(visits) AS total FROM visit_rollups_hourly
+			WHERE url_id = $1 AND hour >= $2 AND hour < $3 GROUP BY bucket
+		) AS visits LEFT JOIN (
+			SELECT `+start+` AS bucket, COUNT(DISTINCT ip_hash) AS total FROM visit_visitors_hourly
+			WHERE url_id = $1 AND hour >= $2 AND hour < $3 GROUP BY bucket
+		) AS visitors ON visitors.bucket = visits.bucket
+		ORDER BY visits.bucket`,
+		urlID, from.UTC(), to.UTC(),
+	)
+	if err != nil {
+		return nil, sl.Wrap(opVisitSeries, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var count entities.VisitCount
+		if err = rows.Scan(&count.Start, &count.Visits, &count.Visitors); err != nil {
+			return nil, sl.Wrap(opVisitSeries, err)
+		}
+		count.Start = count.Start.UTC()
+		series = append(series, count)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, sl.Wrap(opVisitSeries, err)
+	}
+
+	return
+}
+
+// TopReferrers returns up to limit referrers of the url with most visits in
+// the hours starting in [from, to). Empty referrer stands for direct visits
+func (s *Storage) TopReferrers(ctx context.Context, urlID int64, from, to time.Time, limit int) ([]entities.TopValue, error) {
+	top, err := s.topValues(ctx, "visit_referrers_hourly", "referrer", urlID, from, to, limit)
+	if err != nil {
+		return nil, sl.Wrap(opTopReferrers, err)
+	}
+
+	return top, nil
+}
+
+// TopUserAgents returns up to limit user agents of the url with most visits
+// in the hours starting in [from, to)
+func (s *Storage) TopUserAgents(ctx context.Context, urlID int64, from, to time.Time, limit int) ([]entities.TopValue, error) {
+	top, err := s.topValues(ctx, "visit_user_agents_hourly", "user_agent", urlID, from, to, limit)
+	if err != nil {
+		return nil, sl.Wrap(opTopUserAgents, err)
+	}
+
+	return top, nil
+}
+
+// topValues sums visits of the rollup table by its column. Neither of them
+// may come from requests
+func (s *Storage) topValues(
+	ctx context.Context,
+	table, column string,
+	urlID int64,
+	from, to time.Time,
+	limit int,
+) (top []entities.TopValue, err error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+column+", SUM(visits) AS total FROM "+table+`
+		WHERE url_id = $1 AND hour >= $2 AND hour < $3
+		GROUP BY `+column+" ORDER BY total DESC, "+column+` COLLATE "C" LIMIT $4`,
+		urlID, from.UTC(), to.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value entities.TopValue
+		if err = rows.Scan(&value.Value, &value.Visits); err != nil {
+			return nil, err
+		}
+		top = append(top, value)
+	}
+
+	return top, rows.Err()
+}
+
+// TopUrls returns up to limit urls of the user with most visits in the hours
+// starting in [from, to). Removed and expired urls are skipped
+func (s *Storage) TopUrls(ctx context.Context, userID int64, from, to time.Time, limit int) (top []entities.UrlVisits, err error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT urls.id, urls.url, urls.alias, SUM(visit_rollups_hourly.visits) AS total
+		FROM urls JOIN visit_rollups_hourly ON visit_rollups_hourly.url_id = urls.id
+		WHERE urls.user_id = $1 AND hour >= $2 AND hour < $3 AND `+activeUrl+`
+		GROUP BY urls.id ORDER BY total DESC, urls.id LIMIT $4`)
+	if err != nil {
+		return nil, sl.Wrap(opTopUrls, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID, from.UTC(), to.UTC(), limit)
+	if err != nil {
+		return nil, sl.Wrap(opTopUrls, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url entities.UrlVisits
+		if err = rows.Scan(&url.URLID, &url.URL, &url.Alias, &url.Visits); err != nil {
+			return nil, sl.Wrap(opTopUrls, err)
+		}
+		top = append(top, url)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, sl.Wrap(opTopUrls, err)
+	}
+
+	return
+}
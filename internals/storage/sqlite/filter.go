@@ -0,0 +1,97 @@
+package sqlite
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+)
+
+// listQuery builds query of the urls page. Sort column is taken only from
+// the known fields, every value is passed as an argument
+func listQuery(userID int64, filter entities.UrlFilter, after *entities.Cursor, limit int) (string, []any) {
+	conditions := []string{"user_id = ?", activeUrl}
+	args := []any{userID}
+
+	if filter.Domain != "" {
+		conditions = append(conditions, "domain = ?")
+		args = append(args, strings.ToLower(filter.Domain))
+	}
+	if filter.AliasPrefix != "" {
+		conditions = append(conditions, `alias LIKE ? || '%' ESCAPE '\'`)
+		args = append(args, escapeLike(filter.AliasPrefix))
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, "id IN (SELECT url_id FROM url_tags WHERE tag = ?)")
+		args = append(args, filter.Tag)
+	}
+	if query := ftsQuery(filter.Query); query != "" {
+		conditions = append(conditions, "id IN (SELECT rowid FROM urls_fts WHERE urls_fts MATCH ?)")
+		args = append(args, query)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.CreatedFrom.UTC().Format(timeLayout))
+	}
+	if !filter.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.CreatedTo.UTC().Format(timeLayout))
+	}
+	if !filter.UpdatedFrom.IsZero() {
+		conditions = append(conditions, "updated_at >= ?")
+		args = append(args, filter.UpdatedFrom.UTC().Format(timeLayout))
+	}
+	if !filter.UpdatedTo.IsZero() {
+		conditions = append(conditions, "updated_at < ?")
+		args = append(args, filter.UpdatedTo.UTC().Format(timeLayout))
+	}
+
+	column := "created_at"
+	switch filter.SortBy {
+	case entities.SortByUpdatedAt:
+		column = "updated_at"
+	case entities.SortByAlias:
+		column = "alias"
+	}
+
+	comparison, direction := ">", "ASC"
+	if filter.Descending {
+		comparison, direction = "<", "DESC"
+	}
+
+	if after != nil {
+		conditions = append(conditions, "("+column+", id) "+comparison+" (?, ?)")
+		switch column {
+		case "updated_at":
+			args = append(args, after.UpdatedAt.UTC().Format(timeLayout))
+		case "alias":
+			args = append(args, after.Alias)
+		default:
+			args = append(args, after.CreatedAt.UTC().Format(timeLayout))
+		}
+		args = append(args, after.ID)
+	}
+
+	query := "SELECT " + urlColumns + " FROM urls" +
+		" WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY " + column + " " + direction + ", id " + direction +
+		" LIMIT " + strconv.Itoa(limit)
+
+	return query, args
+}
+
+// ftsQuery turns user input into FTS5 query matching all of its words,
+// every word is quoted so the input can't break the query syntax
+func ftsQuery(input string) string {
+	words := strings.Fields(input)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+
+	return strings.Join(words, " ")
+}
+
+// escapeLike escapes LIKE wildcards so the value is matched literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const (
+	opPurgeExpiredUrls = "sqlite.PurgeExpiredUrls"
+	opPurgeDeletedUrls = "sqlite.PurgeDeletedUrls"
+)
+
+// PurgeExpiredUrls deletes up to limit expired urls and returns how many were
+// deleted. With archive they are copied to urls_archive first
+func (s *Storage) PurgeExpiredUrls(ctx context.Context, archive bool, limit int) (purged int64, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, sl.Wrap(opPurgeExpiredUrls, err)
+	}
+	defer tx.Rollback()
+
+	// both statements must see the same rows, so the time is fixed here
+	// instead of CURRENT_TIMESTAMP evaluated by each of them
+	now := time.Now().UTC().Format(timeLayout)
+	const expired = `SELECT id FROM urls
+		WHERE expires_at IS NOT NULL AND expires_at <= ?
+		ORDER BY expires_at LIMIT ?`
+
+	if archive {
+		if _, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO urls_archive
+			(id, user_id, url, alias, title, created_at, updated_at, expires_at)
+			SELECT id, user_id, url, alias, title, created_at, updated_at, expires_at
+			FROM urls WHERE id IN (`+expired+`)`, now, limit,
+		); err != nil {
+			return 0, sl.Wrap(opPurgeExpiredUrls, err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM urls WHERE id IN ("+expired+")", now, limit)
+	if err != nil {
+		return 0, sl.Wrap(opPurgeExpiredUrls, err)
+	}
+
+	purged, err = res.RowsAffected()
+	if err != nil {
+		return 0, sl.Wrap(opPurgeExpiredUrls, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, sl.Wrap(opPurgeExpiredUrls, err)
+	}
+
+	return
+}
+
+// PurgeDeletedUrls deletes up to limit urls removed to the trash before the
+// given time and returns how many were deleted. Their aliases become free
+func (s *Storage) PurgeDeletedUrls(ctx context.Context, before time.Time, limit int) (purged int64, err error) {
+	stmt, err := s.db.PrepareContext(ctx, `DELETE FROM urls WHERE id IN (
+		SELECT id FROM urls WHERE deleted_at IS NOT NULL AND deleted_at <= ? ORDER BY deleted_at LIMIT ?
+	)`)
+	if err != nil {
+		return 0, sl.Wrap(opPurgeDeletedUrls, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, before.UTC().Format(timeLayout), limit)
+	if err != nil {
+		return 0, sl.Wrap(opPurgeDeletedUrls, err)
+	}
+
+	purged, err = res.RowsAffected()
+	if err != nil {
+		return 0, sl.Wrap(opPurgeDeletedUrls, err)
+	}
+
+	return
+}
@@ -2,8 +2,13 @@ package sqlite
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	neturl "net/url"
+	"strings"
+	"time"
 
 	"github.com/mattn/go-sqlite3"
 	_ "github.com/mattn/go-sqlite3"
@@ -15,63 +20,394 @@ import (
 const (
 	opNewStorage = "sqlite.NewStorage"
 	opSaveUrl    = "sqlite.SaveUrl"
+	opUrl        = "sqlite.Url"
+	opUrlByID    = "sqlite.UrlByID"
+	opUrlByURL   = "sqlite.UrlByURL"
+	opPublicUrl  = "sqlite.PublicUrl"
+	opUrlList    = "sqlite.UrlList"
+	opUpdateUrl  = "sqlite.UpdateUrl"
+	opRemoveUrl  = "sqlite.RemoveUrl"
+	opRestoreUrl = "sqlite.RestoreUrl"
+
+	// timeLayout is the format of CURRENT_TIMESTAMP values
+	timeLayout = "2006-01-02 15:04:05"
+
+	// activeUrl matches urls which are neither removed nor expired,
+	// others are treated as not found
+	activeUrl = "deleted_at IS NULL AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)"
+
+	// urlColumns are the columns of entities.URL, they are read by scanUrl
+	urlColumns = "id, url, alias, title, created_at, updated_at, expires_at"
 )
 
 type Storage struct {
 	db *sql.DB
+	// globalAliases makes aliases unique across all users,
+	// otherwise they are unique only within urls of one user
+	globalAliases bool
 }
 
-func NewStorage(storagePath string) (*Storage, error) {
-	db, err := sql.Open("sqlite3", storagePath)
+func NewStorage(storagePath string, globalAliases bool) (*Storage, error) {
+	// transactions take the write lock when they begin. Deferred ones take it
+	// on the first write and fail with "database is locked" right away when
+	// another transaction is writing, without waiting for the busy timeout
+	separator := "?"
+	if strings.Contains(storagePath, "?") {
+		separator = "&"
+	}
+
+	db, err := sql.Open("sqlite3", storagePath+separator+"_txlock=immediate")
 	if err != nil {
-		return nil, sl.ErrUpLevel(opNewStorage, err.Error())
+		return nil, sl.Wrap(opNewStorage, err)
 	}
 	return &Storage{
-		db: db,
+		db:            db,
+		globalAliases: globalAliases,
 	}, nil
 }
 
-func (s *Storage) SaveUrl(ctx context.Context, url, alias string) (urlID int64, err error) {
-	stmt, err := s.db.PrepareContext(ctx, "INSERT INTO urls (user_id, url, alias) VALUES (?, ?, ?)")
+// Close closes the database
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+func (s *Storage) SaveUrl(ctx context.Context, userID int64, url, alias string, meta entities.UrlMeta) (urlID int64, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, sl.ErrUpLevel(opSaveUrl, err.Error())
+		return 0, sl.Wrap(opSaveUrl, err)
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
 	var sqliteErr sqlite3.Error
-	// TODO: insert correct user id
-	res, err := stmt.ExecContext(ctx, url, 1, alias)
+	res, err := tx.ExecContext(ctx, `INSERT INTO urls (user_id, url, alias, title, domain, url_hash, expires_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT (? AND EXISTS (SELECT 1 FROM urls WHERE alias = ?))`,
+		userID, url, alias, meta.Title, domainOf(url), urlHash(url), expiresAt(meta.ExpiresAt), s.globalAliases, alias,
+	)
 	if err != nil {
 		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
-			return 0, sl.ErrUpLevel(opSaveUrl, storage.ErrAliasExists.Error())
+			return 0, sl.Wrap(opSaveUrl, storage.ErrAliasExists)
 		}
-		return 0, sl.ErrUpLevel(opSaveUrl, err.Error())
+		return 0, sl.Wrap(opSaveUrl, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, sl.Wrap(opSaveUrl, err)
+	}
+	if affected == 0 {
+		return 0, sl.Wrap(opSaveUrl, storage.ErrAliasExists)
 	}
 
 	urlID, err = res.LastInsertId()
 	if err != nil {
-		return 0, sl.ErrUpLevel(opSaveUrl, err.Error())
+		return 0, sl.Wrap(opSaveUrl, err)
+	}
+
+	if err = setTags(ctx, tx, urlID, meta.Tags); err != nil {
+		return 0, sl.Wrap(opSaveUrl, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, sl.Wrap(opSaveUrl, err)
+	}
+
+	return
+}
+
+func (s *Storage) Url(ctx context.Context, userID int64, alias string) (url entities.URL, err error) {
+	stmt, err := s.db.PrepareContext(ctx,
+		"SELECT "+urlColumns+" FROM urls WHERE user_id = ? AND alias = ? AND "+activeUrl,
+	)
+	if err != nil {
+		return entities.URL{}, sl.Wrap(opUrl, err)
+	}
+	defer stmt.Close()
+
+	err = scanUrl(stmt.QueryRowContext(ctx, userID, alias), &url)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.URL{}, sl.Wrap(opUrl, storage.ErrAliasNotFound)
+		}
+		return entities.URL{}, sl.Wrap(opUrl, err)
+	}
+
+	return
+}
+
+func (s *Storage) UrlByID(ctx context.Context, userID, urlID int64) (url entities.URL, err error) {
+	stmt, err := s.db.PrepareContext(ctx,
+		"SELECT "+urlColumns+" FROM urls WHERE user_id = ? AND id = ? AND "+activeUrl,
+	)
+	if err != nil {
+		return entities.URL{}, sl.Wrap(opUrlByID, err)
+	}
+	defer stmt.Close()
+
+	err = scanUrl(stmt.QueryRowContext(ctx, userID, urlID), &url)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.URL{}, sl.Wrap(opUrlByID, storage.ErrAliasNotFound)
+		}
+		return entities.URL{}, sl.Wrap(opUrlByID, err)
+	}
+
+	return
+}
+
+// UrlByURL returns the oldest url of the user with the same target url
+func (s *Storage) UrlByURL(ctx context.Context, userID int64, targetURL string) (url entities.URL, err error) {
+	// rows saved before url_hash was added are hashed by BackfillUrlHashes
+	stmt, err := s.db.PrepareContext(ctx, "SELECT "+urlColumns+` FROM urls
+		WHERE user_id = ? AND url_hash = ? AND url = ? AND `+activeUrl+`
+		ORDER BY id LIMIT 1`,
+	)
+	if err != nil {
+		return entities.URL{}, sl.Wrap(opUrlByURL, err)
+	}
+	defer stmt.Close()
+
+	err = scanUrl(stmt.QueryRowContext(ctx, userID, urlHash(targetURL), targetURL), &url)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.URL{}, sl.Wrap(opUrlByURL, storage.ErrAliasNotFound)
+		}
+		return entities.URL{}, sl.Wrap(opUrlByURL, err)
+	}
+
+	return
+}
+
+// PublicUrl returns url by alias among urls of all users. When aliases are
+// unique only per user and several users have the alias, it is not found
+func (s *Storage) PublicUrl(ctx context.Context, alias string) (url entities.URL, err error) {
+	stmt, err := s.db.PrepareContext(ctx,
+		"SELECT "+urlColumns+" FROM urls WHERE alias = ? AND "+activeUrl+" LIMIT 2",
+	)
+	if err != nil {
+		return entities.URL{}, sl.Wrap(opPublicUrl, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, alias)
+	if err != nil {
+		return entities.URL{}, sl.Wrap(opPublicUrl, err)
+	}
+	defer rows.Close()
+
+	found := 0
+	for rows.Next() {
+		if err := scanUrl(rows, &url); err != nil {
+			return entities.URL{}, sl.Wrap(opPublicUrl, err)
+		}
+		found++
+	}
+	if err := rows.Err(); err != nil {
+		return entities.URL{}, sl.Wrap(opPublicUrl, err)
+	}
+	if found != 1 {
+		return entities.URL{}, sl.Wrap(opPublicUrl, storage.ErrAliasNotFound)
+	}
+
+	return
+}
+
+// UrlList returns up to limit urls of the user matching the filter in its
+// order. The list starts after the cursor, nil cursor means the beginning
+func (s *Storage) UrlList(
+	ctx context.Context,
+	userID int64,
+	filter entities.UrlFilter,
+	after *entities.Cursor,
+	limit int,
+) (urls []entities.URL, err error) {
+	query, args := listQuery(userID, filter, after, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, sl.Wrap(opUrlList, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url entities.URL
+		if err := scanUrl(rows, &url); err != nil {
+			return nil, sl.Wrap(opUrlList, err)
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, sl.Wrap(opUrlList, err)
+	}
+
+	return
+}
+
+// UpdateUrl sets new url, alias and meta for the url of the user with given
+// ID. Empty values keep the current ones
+func (s *Storage) UpdateUrl(ctx context.Context, userID, urlID int64, url, alias string, meta entities.UrlMeta) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sl.Wrap(opUpdateUrl, err)
+	}
+	defer tx.Rollback()
+
+	var sqliteErr sqlite3.Error
+	res, err := tx.ExecContext(ctx, `UPDATE urls
+		SET url = COALESCE(NULLIF(?, ''), url),
+			domain = COALESCE(NULLIF(?, ''), domain),
+			url_hash = COALESCE(NULLIF(?, ''), url_hash),
+			alias = COALESCE(NULLIF(?, ''), alias),
+			title = COALESCE(NULLIF(?, ''), title),
+			expires_at = CASE WHEN ? THEN ? ELSE expires_at END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND id = ? AND `+activeUrl+`
+			AND NOT (? AND EXISTS (SELECT 1 FROM urls WHERE alias = ? AND id != ?))`,
+		url, domainOf(url), urlHash(url), alias, meta.Title, meta.ExpiresAt != nil, expiresAt(meta.ExpiresAt),
+		userID, urlID, s.globalAliases, alias, urlID,
+	)
+	if err != nil {
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+			return sl.Wrap(opUpdateUrl, storage.ErrAliasExists)
+		}
+		return sl.Wrap(opUpdateUrl, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return sl.Wrap(opUpdateUrl, err)
+	}
+	if affected == 0 {
+		// nothing was updated either because there is no such url
+		// or because the new alias is taken by another user
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM urls WHERE user_id = ? AND id = ? AND "+activeUrl+")", userID, urlID,
+		).Scan(&exists); err != nil {
+			return sl.Wrap(opUpdateUrl, err)
+		}
+		if !exists {
+			return sl.Wrap(opUpdateUrl, storage.ErrAliasNotFound)
+		}
+		return sl.Wrap(opUpdateUrl, storage.ErrAliasExists)
+	}
+
+	if meta.Tags != nil {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM url_tags WHERE url_id = ?", urlID); err != nil {
+			return sl.Wrap(opUpdateUrl, err)
+		}
+		if err = setTags(ctx, tx, urlID, meta.Tags); err != nil {
+			return sl.Wrap(opUpdateUrl, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return sl.Wrap(opUpdateUrl, err)
+	}
+
+	return nil
+}
+
+// RemoveUrl moves the url of the user with given alias to the trash and
+// returns its ID. The alias stays taken until the url is purged
+func (s *Storage) RemoveUrl(ctx context.Context, userID int64, alias string) (urlID int64, err error) {
+	stmt, err := s.db.PrepareContext(ctx,
+		"UPDATE urls SET deleted_at = CURRENT_TIMESTAMP WHERE user_id = ? AND alias = ? AND "+activeUrl+" RETURNING id",
+	)
+	if err != nil {
+		return 0, sl.Wrap(opRemoveUrl, err)
+	}
+	defer stmt.Close()
+
+	if err = stmt.QueryRowContext(ctx, userID, alias).Scan(&urlID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, sl.Wrap(opRemoveUrl, storage.ErrAliasNotFound)
+		}
+		return 0, sl.Wrap(opRemoveUrl, err)
+	}
+
+	return
+}
+
+// RestoreUrl takes the url of the user with given alias out of the trash.
+// Expired urls are not restored
+func (s *Storage) RestoreUrl(ctx context.Context, userID int64, alias string) (url entities.URL, err error) {
+	return s.restoreUrl(ctx, "alias = ?", userID, alias)
+}
+
+func (s *Storage) RestoreUrlByID(ctx context.Context, userID, urlID int64) (url entities.URL, err error) {
+	return s.restoreUrl(ctx, "id = ?", userID, urlID)
+}
+
+func (s *Storage) restoreUrl(ctx context.Context, condition string, userID int64, value any) (url entities.URL, err error) {
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE urls SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND `+condition+` AND deleted_at IS NOT NULL
+			AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		RETURNING id, url, alias, title`,
+	)
+	if err != nil {
+		return entities.URL{}, sl.Wrap(opRestoreUrl, err)
+	}
+	defer stmt.Close()
+
+	if err = stmt.QueryRowContext(ctx, userID, value).Scan(&url.ID, &url.URL, &url.Alias, &url.Title); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.URL{}, sl.Wrap(opRestoreUrl, storage.ErrAliasNotFound)
+		}
+		return entities.URL{}, sl.Wrap(opRestoreUrl, err)
 	}
 
 	return
 }
 
-func (s *Storage) Url(ctx context.Context, alias string) (url entities.URL, err error) {
-	// TODO: implement orm project system
-	panic("implement me")
+func setTags(ctx context.Context, tx *sql.Tx, urlID int64, tags []string) error {
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT OR IGNORE INTO url_tags (url_id, tag) VALUES (?, ?)", urlID, tag,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (s *Storage) UrlList(ctx context.Context, alias string) (urls []entities.URL, err error) {
-	// TODO: implement orm project system
-	panic("implement me")
+// scanUrl scans the url selected with urlColumns
+func scanUrl(row rowScanner, url *entities.URL) error {
+	var expires sql.NullTime
+	if err := row.Scan(&url.ID, &url.URL, &url.Alias, &url.Title, &url.CreatedAt, &url.UpdatedAt, &expires); err != nil {
+		return err
+	}
+	url.ExpiresAt = expires.Time
+
+	return nil
 }
 
-func (s *Storage) UpdateUrl(ctx context.Context, urlID int64, alias string) (err error) {
-	// TODO: implement orm project system
-	panic("implement me")
+// expiresAt returns value of expires_at column, NULL for nil or zero time
+func expiresAt(t *time.Time) any {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+
+	return t.UTC().Format(timeLayout)
+}
+
+// urlHash returns hex encoded SHA-256 of the url, empty for empty url
+func urlHash(url string) string {
+	if url == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
 }
 
-func (s *Storage) RemoveUrl(ctx context.Context, alias string) (err error) {
-	// TODO: implement orm project system
-	panic("implement me")
+// domainOf returns lowercased host of the url, empty for invalid urls
+func domainOf(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
 }
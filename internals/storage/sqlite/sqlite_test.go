@@ -0,0 +1,164 @@
+//go:build sqlite_fts5
+
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/nhassl3/url-saver/internals/storage"
+	"github.com/nhassl3/url-saver/internals/storage/sqlite"
+	"github.com/nhassl3/url-saver/internals/storage/storagetest"
+)
+
+// newStorage returns storage over a new migrated database
+func newStorage(t *testing.T, globalAliases bool) *sqlite.Storage {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "urlsaver.db")
+
+	m, err := migrate.New("file://../../../migrations", "sqlite3://"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if srcErr, dbErr := m.Close(); srcErr != nil || dbErr != nil {
+		t.Fatal(srcErr, dbErr)
+	}
+
+	s, err := sqlite.NewStorage(path, globalAliases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, globalAliases bool) storagetest.Storage {
+		return newStorage(t, globalAliases)
+	})
+}
+
+func TestAliasUniquePerUserRollback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urlsaver.db")
+
+	m, err := migrate.New("file://../../../migrations", "sqlite3://"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if err = m.Migrate(2); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`INSERT INTO urls (user_id, url, alias) VALUES
+		(1, 'https://example.com/1', 'shared'),
+		(2, 'https://example.com/2', 'shared'),
+		(1, 'https://example.com/3', 'own')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	countUrls := func() (count int) {
+		t.Helper()
+		if err := db.QueryRow("SELECT COUNT(*) FROM urls").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	err = m.Migrate(1)
+	if err == nil || !strings.Contains(err.Error(), "aliases are shared by several users") {
+		t.Fatalf("Migrate(1) error = %v, want the shared aliases to be reported", err)
+	}
+	if got := countUrls(); got != 3 {
+		t.Fatalf("%d urls left after the failed rollback, want 3", got)
+	}
+
+	// after the duplicates are resolved the dirty version is forced back
+	if _, err = db.Exec("UPDATE urls SET alias = 'shared-2' WHERE user_id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Force(2); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Migrate(1); err != nil {
+		t.Fatalf("Migrate(1) error = %v", err)
+	}
+	if got := countUrls(); got != 3 {
+		t.Errorf("%d urls left after the rollback, want 3", got)
+	}
+}
+
+func TestBackfillUrlHashes(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urlsaver.db")
+
+	m, err := migrate.New("file://../../../migrations", "sqlite3://"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	// urls are saved before url_hash is added
+	if err = m.Migrate(5); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// more urls than one batch hashes
+	const saved = 1201
+	_, err = db.Exec(`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO urls (user_id, url, alias) SELECT 1, 'https://example.com/' || i, 'alias' || i FROM n`, saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := sqlite.NewStorage(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err = s.UrlByURL(ctx, 1, "https://example.com/7"); !errors.Is(err, storage.ErrAliasNotFound) {
+		t.Fatalf("UrlByURL() before backfill error = %v, want %v", err, storage.ErrAliasNotFound)
+	}
+
+	filled, err := s.BackfillUrlHashes(ctx)
+	if err != nil || filled != saved {
+		t.Fatalf("BackfillUrlHashes() = %d, %v, want %d", filled, err, saved)
+	}
+
+	url, err := s.UrlByURL(ctx, 1, "https://example.com/7")
+	if err != nil || url.Alias != "alias7" {
+		t.Errorf("UrlByURL() = %q, %v, want the url aliased alias7", url.Alias, err)
+	}
+
+	if filled, err = s.BackfillUrlHashes(ctx); err != nil || filled != 0 {
+		t.Errorf("second BackfillUrlHashes() = %d, %v, want nothing hashed", filled, err)
+	}
+}
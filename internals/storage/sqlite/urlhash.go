@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const (
+	opBackfillUrlHashes = "sqlite.BackfillUrlHashes"
+
+	// backfillBatch is the number of urls hashed in one transaction
+	backfillBatch = 500
+)
+
+// BackfillUrlHashes sets url_hash of urls saved before the column was added,
+// SQLite can't compute SHA-256 itself so the migration leaves them NULL.
+// It returns how many urls were hashed, the next call hashes none
+func (s *Storage) BackfillUrlHashes(ctx context.Context) (filled int64, err error) {
+	for {
+		n, err := s.backfillUrlHashes(ctx)
+		if err != nil {
+			return filled, sl.Wrap(opBackfillUrlHashes, err)
+		}
+		if n == 0 {
+			return filled, nil
+		}
+		filled += n
+	}
+}
+
+// backfillUrlHashes hashes one batch of urls without a hash
+func (s *Storage) backfillUrlHashes(ctx context.Context) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, url FROM urls WHERE url_hash IS NULL LIMIT ?", backfillBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	hashes := make(map[int64]string, backfillBatch)
+	for rows.Next() {
+		var (
+			id  int64
+			url string
+		)
+		if err = rows.Scan(&id, &url); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		hashes[id] = urlHash(url)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(hashes) == 0 {
+		return 0, nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "UPDATE urls SET url_hash = ? WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for id, hash := range hashes {
+		if _, err = stmt.ExecContext(ctx, hash, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int64(len(hashes)), nil
+}
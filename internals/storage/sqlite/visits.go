@@ -0,0 +1,274 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/lib/logger/sl"
+)
+
+const (
+	opSaveVisits    = "sqlite.SaveVisits"
+	opVisitStats    = "sqlite.VisitStats"
+	opVisitSeries   = "sqlite.VisitSeries"
+	opTopReferrers  = "sqlite.TopReferrers"
+	opTopUserAgents = "sqlite.TopUserAgents"
+	opTopUrls       = "sqlite.TopUrls"
+
+	// hourLayout truncates time of the visit to the hour of its rollups
+	hourLayout = "2006-01-02 15:00:00"
+)
+
+// bucketStart are expressions truncating the hour of rollups to the start
+// of its bucket, weeks start on Monday. They are strings, not TIMESTAMP
+// columns, so the driver doesn't parse them
+var bucketStart = map[entities.Bucket]string{
+	entities.BucketHour: "strftime('%Y-%m-%d %H:00:00', hour)",
+	entities.BucketDay:  "strftime('%Y-%m-%d 00:00:00', hour)",
+	entities.BucketWeek: "strftime('%Y-%m-%d 00:00:00', hour, 'weekday 0', '-6 days')",
+}
+
+// SaveVisits saves the batch of visits and adds them to the hourly rollups
+// in one transaction
+func (s *Storage) SaveVisits(ctx context.Context, visits []entities.Visit) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sl.Wrap(opSaveVisits, err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		`INSERT INTO visits (url_id, alias, visited_at, referrer, user_agent, ip_hash, country)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO visit_rollups_hourly (url_id, hour, visits) VALUES (?, ?, 1)
+		ON CONFLICT (url_id, hour) DO UPDATE SET visits = visits + 1`,
+		`INSERT OR IGNORE INTO visit_visitors_hourly (url_id, hour, ip_hash) VALUES (?, ?, ?)`,
+		`INSERT INTO visit_referrers_hourly (url_id, hour, referrer, visits) VALUES (?, ?, ?, 1)
+		ON CONFLICT (url_id, hour, referrer) DO UPDATE SET visits = visits + 1`,
+		`INSERT INTO visit_user_agents_hourly (url_id, hour, user_agent, visits) VALUES (?, ?, ?, 1)
+		ON CONFLICT (url_id, hour, user_agent) DO UPDATE SET visits = visits + 1`,
+	}
+
+	stmts := make([]*sql.Stmt, 0, len(queries))
+	defer func() {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}()
+	for _, query := range queries {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return sl.Wrap(opSaveVisits, err)
+		}
+		stmts = append(stmts, stmt)
+	}
+	saveVisit, addVisit, addVisitor, addReferrer, addUserAgent := stmts[0], stmts[1], stmts[2], stmts[3], stmts[4]
+
+	for _, visit := range visits {
+		visitedAt := visit.VisitedAt.UTC()
+		hour := visitedAt.Format(hourLayout)
+
+		if _, err = saveVisit.ExecContext(ctx,
+			visit.URLID, visit.Alias, visitedAt.Format(timeLayout),
+			visit.Referrer, visit.UserAgent, visit.IPHash, visit.Country,
+		); err != nil {
+			return sl.Wrap(opSaveVisits, err)
+		}
+
+		if _, err = addVisit.ExecContext(ctx, visit.URLID, hour); err != nil {
+			return sl.Wrap(opSaveVisits, err)
+		}
+		if visit.IPHash != "" {
+			if _, err = addVisitor.ExecContext(ctx, visit.URLID, hour, visit.IPHash); err != nil {
+				return sl.Wrap(opSaveVisits, err)
+			}
+		}
+		if _, err = addReferrer.ExecContext(ctx, visit.URLID, hour, visit.Referrer); err != nil {
+			return sl.Wrap(opSaveVisits, err)
+		}
+		if _, err = addUserAgent.ExecContext(ctx, visit.URLID, hour, visit.UserAgent); err != nil {
+			return sl.Wrap(opSaveVisits, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return sl.Wrap(opSaveVisits, err)
+	}
+
+	return nil
+}
+
+// VisitStats counts visits and distinct visitors of the url in the hours
+// starting in [from, to)
+func (s *Storage) VisitStats(ctx context.Context, urlID int64, from, to time.Time) (stats entities.VisitStats, err error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT
+		(SELECT COALESCE(SUM(visits), 0) FROM visit_rollups_hourly WHERE url_id = ? AND hour >= ? AND hour < ?),
+		(SELECT COUNT(DISTINCT ip_hash) FROM visit_visitors_hourly WHERE url_id = ? AND hour >= ? AND hour < ?)`)
+	if err != nil {
+		return entities.VisitStats{}, sl.Wrap(opVisitStats, err)
+	}
+	defer stmt.Close()
+
+	fromHour, toHour := from.UTC().Format(timeLayout), to.UTC().Format(timeLayout)
+	err = stmt.QueryRowContext(ctx, urlID, fromHour, toHour, urlID, fromHour, toHour).Scan(&stats.Visits, &stats.Visitors)
+	if err != nil {
+		return entities.VisitStats{}, sl.Wrap(opVisitStats, err)
+	}
+
+	return
+}
+
+// VisitSeries counts visits and distinct visitors of the url in the hours
+// starting in [from, to) per bucket. Buckets without visits are omitted
+func (s *Storage) VisitSeries(
+	ctx context.Context,
+	urlID int64,
+	bucket entities.Bucket,
+	from, to time.Time,
+) (series []entities.VisitCount, err error) {
+	start, ok := bucketStart[bucket]
+	if !ok {
+		return nil, sl.Wrap(opVisitSeries, fmt.Errorf("unknown bucket %q", bucket))
+	}
+
+	args := []any{urlID, from.UTC().Format(timeLayout), to.UTC().Format(timeLayout)}
+
+	starts, visits, err := s.bucketCounts(ctx, "SELECT "+start+` AS bucket, SUM(visits) FROM visit_rollups_hourly
+		WHERE url_id = ? AND hour >= ? AND hour < ? GROUP BY bucket ORDER BY bucket`, args...)
+	if err != nil {
+		return nil, sl.Wrap(opVisitSeries, err)
+	}
+
+	// visitors of a bucket are counted apart, they may come back in many hours of it
+	_, visitors, err := s.bucketCounts(ctx, "SELECT "+start+` AS bucket, COUNT(DISTINCT ip_hash) FROM visit_visitors_hourly
+		WHERE url_id = ? AND hour >= ? AND hour < ? GROUP BY bucket`, args...)
+	if err != nil {
+		return nil, sl.Wrap(opVisitSeries, err)
+	}
+
+	series = make([]entities.VisitCount, 0, len(starts))
+	for _, startAt := range starts {
+		count := entities.VisitCount{
+			VisitStats: entities.VisitStats{Visits: visits[startAt], Visitors: visitors[startAt]},
+		}
+		if count.Start, err = time.Parse(timeLayout, startAt); err != nil {
+			return nil, sl.Wrap(opVisitSeries, err)
+		}
+		series = append(series, count)
+	}
+
+	return
+}
+
+// bucketCounts runs the query selecting starts of buckets with their counts,
+// starts are returned in order of the rows
+func (s *Storage) bucketCounts(ctx context.Context, query string, args ...any) (starts []string, counts map[string]int64, err error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	counts = make(map[string]int64)
+	for rows.Next() {
+		var (
+			startAt string
+			count   int64
+		)
+		if err = rows.Scan(&startAt, &count); err != nil {
+			return nil, nil, err
+		}
+
+		starts = append(starts, startAt)
+		counts[startAt] = count
+	}
+
+	return starts, counts, rows.Err()
+}
+
+// TopReferrers returns up to limit referrers of the url with most visits in
+// the hours starting in [from, to). Empty referrer stands for direct visits
+func (s *Storage) TopReferrers(ctx context.Context, urlID int64, from, to time.Time, limit int) ([]entities.TopValue, error) {
+	top, err := s.topValues(ctx, "visit_referrers_hourly", "referrer", urlID, from, to, limit)
+	if err != nil {
+		return nil, sl.Wrap(opTopReferrers, err)
+	}
+
+	return top, nil
+}
+
+// TopUserAgents returns up to limit user agents of the url with most visits
+// in the hours starting in [from, to)
+func (s *Storage) TopUserAgents(ctx context.Context, urlID int64, from, to time.Time, limit int) ([]entities.TopValue, error) {
+	top, err := s.topValues(ctx, "visit_user_agents_hourly", "user_agent", urlID, from, to, limit)
+	if err != nil {
+		return nil, sl.Wrap(opTopUserAgents, err)
+	}
+
+	return top, nil
+}
+
+// topValues sums visits of the rollup table by its column. Neither of them
+// may come from requests
+func (s *Storage) topValues(
+	ctx context.Context,
+	table, column string,
+	urlID int64,
+	from, to time.Time,
+	limit int,
+) (top []entities.TopValue, err error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+column+", SUM(visits) AS total FROM "+table+`
+		WHERE url_id = ? AND hour >= ? AND hour < ?
+		GROUP BY `+column+" ORDER BY total DESC, "+column+" LIMIT ?",
+		urlID, from.UTC().Format(timeLayout), to.UTC().Format(timeLayout), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value entities.TopValue
+		if err = rows.Scan(&value.Value, &value.Visits); err != nil {
+			return nil, err
+		}
+		top = append(top, value)
+	}
+
+	return top, rows.Err()
+}
+
+// TopUrls returns up to limit urls of the user with most visits in the hours
+// starting in [from, to). Removed and expired urls are skipped
+func (s *Storage) TopUrls(ctx context.Context, userID int64, from, to time.Time, limit int) (top []entities.UrlVisits, err error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT urls.id, urls.url, urls.alias, SUM(visit_rollups_hourly.visits) AS total
+		FROM urls JOIN visit_rollups_hourly ON visit_rollups_hourly.url_id = urls.id
+		WHERE urls.user_id = ? AND hour >= ? AND hour < ? AND `+activeUrl+`
+		GROUP BY urls.id ORDER BY total DESC, urls.id LIMIT ?`)
+	if err != nil {
+		return nil, sl.Wrap(opTopUrls, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID, from.UTC().Format(timeLayout), to.UTC().Format(timeLayout), limit)
+	if err != nil {
+		return nil, sl.Wrap(opTopUrls, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url entities.UrlVisits
+		if err = rows.Scan(&url.URLID, &url.URL, &url.Alias, &url.Visits); err != nil {
+			return nil, sl.Wrap(opTopUrls, err)
+		}
+		top = append(top, url)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, sl.Wrap(opTopUrls, err)
+	}
+
+	return
+}
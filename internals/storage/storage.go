@@ -6,4 +6,6 @@ var (
 	ErrAliasExists   = errors.New("alias exists")
 	ErrAliasNotFound = errors.New("alias not found")
 	ErrUrlIsInvalid  = errors.New("url is invalid")
+
+	ErrApiKeyNotFound = errors.New("api key not found")
 )
@@ -0,0 +1,721 @@
+// Package storagetest is the conformance suite of url storages. Every
+// backend runs it from its own tests, so they all behave the same way:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T, globalAliases bool) storagetest.Storage {
+//			return newStorage(t, globalAliases)
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+	"github.com/nhassl3/url-saver/internals/storage"
+)
+
+const (
+	userID      = 1
+	otherUserID = 2
+
+	// workers is the number of goroutines racing in concurrency tests
+	workers = 16
+)
+
+// Storage is checked by the suite
+type Storage interface {
+	urlsaver.SaverUrl
+	urlsaver.ProviderUrl
+	urlsaver.UpdaterUrl
+}
+
+// NewStorage returns empty storage. With globalAliases aliases are unique
+// across all users, otherwise only within urls of one user. Storages of one
+// run are not used in parallel
+type NewStorage func(t *testing.T, globalAliases bool) Storage
+
+// Run runs the suite against storages made by newStorage
+func Run(t *testing.T, newStorage NewStorage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, newStorage NewStorage)
+	}{
+		{name: "Uniqueness", test: testUniqueness},
+		{name: "NotFound", test: testNotFound},
+		{name: "PublicUrl", test: testPublicUrl},
+		{name: "UrlByURL", test: testUrlByURL},
+		{name: "Update", test: testUpdate},
+		{name: "UpdateTags", test: testUpdateTags},
+		{name: "RemoveAndRestore", test: testRemoveAndRestore},
+		{name: "Expired", test: testExpired},
+		{name: "Pagination", test: testPagination},
+		{name: "Filters", test: testFilters},
+		{name: "Concurrency", test: testConcurrency},
+		{name: "ContextCancellation", test: testContextCancellation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { tt.test(t, newStorage) })
+	}
+}
+
+// save saves the url and fails the test on error
+func save(t *testing.T, s Storage, userID int64, url, alias string, meta entities.UrlMeta) int64 {
+	t.Helper()
+
+	urlID, err := s.SaveUrl(context.Background(), userID, url, alias, meta)
+	if err != nil {
+		t.Fatalf("SaveUrl(%d, %q, %q) error = %v", userID, url, alias, err)
+	}
+
+	return urlID
+}
+
+// wantErr fails the test unless err is target, nil target means no error
+func wantErr(t *testing.T, call string, err, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Errorf("%s error = %v, want %v", call, err, target)
+	}
+}
+
+func testUniqueness(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		globalAliases bool
+		userID        int64
+		want          error
+	}{
+		{name: "same user, global aliases", globalAliases: true, userID: userID, want: storage.ErrAliasExists},
+		{name: "other user, global aliases", globalAliases: true, userID: otherUserID, want: storage.ErrAliasExists},
+		{name: "same user, per user aliases", globalAliases: false, userID: userID, want: storage.ErrAliasExists},
+		{name: "other user, per user aliases", globalAliases: false, userID: otherUserID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t, tt.globalAliases)
+			urlID := save(t, s, userID, "https://example.com", "taken", entities.UrlMeta{})
+
+			otherID, err := s.SaveUrl(ctx, tt.userID, "https://example.org", "taken", entities.UrlMeta{})
+			wantErr(t, "SaveUrl()", err, tt.want)
+			if tt.want == nil && otherID == urlID {
+				t.Errorf("SaveUrl() = %d, want ID other than %d", otherID, urlID)
+			}
+
+			// the first url is kept as it was
+			url, err := s.Url(ctx, userID, "taken")
+			if err != nil || url.ID != urlID || url.URL != "https://example.com" {
+				t.Errorf("Url() = %+v, %v, want url %d", url, err, urlID)
+			}
+		})
+	}
+}
+
+func testNotFound(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t, true)
+	urlID := save(t, s, userID, "https://example.com", "own", entities.UrlMeta{})
+
+	// unknown urls and urls of other users are not found alike
+	for _, user := range []int64{userID, otherUserID} {
+		alias, id, targetURL := "unknown", urlID+100, "https://unknown.example"
+		if user == otherUserID {
+			alias, id, targetURL = "own", urlID, "https://example.com"
+		}
+
+		t.Run(fmt.Sprintf("user %d", user), func(t *testing.T) {
+			_, err := s.Url(ctx, user, alias)
+			wantErr(t, "Url()", err, storage.ErrAliasNotFound)
+			_, err = s.UrlByID(ctx, user, id)
+			wantErr(t, "UrlByID()", err, storage.ErrAliasNotFound)
+			_, err = s.UrlByURL(ctx, user, targetURL)
+			wantErr(t, "UrlByURL()", err, storage.ErrAliasNotFound)
+			err = s.UpdateUrl(ctx, user, id, "https://example.org", "", entities.UrlMeta{})
+			wantErr(t, "UpdateUrl()", err, storage.ErrAliasNotFound)
+			_, err = s.RemoveUrl(ctx, user, alias)
+			wantErr(t, "RemoveUrl()", err, storage.ErrAliasNotFound)
+			_, err = s.RestoreUrl(ctx, user, alias)
+			wantErr(t, "RestoreUrl()", err, storage.ErrAliasNotFound)
+			_, err = s.RestoreUrlByID(ctx, user, id)
+			wantErr(t, "RestoreUrlByID()", err, storage.ErrAliasNotFound)
+		})
+	}
+
+	_, err := s.PublicUrl(ctx, "unknown")
+	wantErr(t, "PublicUrl()", err, storage.ErrAliasNotFound)
+
+	// failed calls change nothing
+	url, err := s.UrlByID(ctx, userID, urlID)
+	if err != nil || url.URL != "https://example.com" || url.Alias != "own" {
+		t.Errorf("UrlByID() = %+v, %v, want the saved url", url, err)
+	}
+}
+
+func testPublicUrl(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+
+	t.Run("global aliases", func(t *testing.T) {
+		s := newStorage(t, true)
+		urlID := save(t, s, userID, "https://example.com", "public", entities.UrlMeta{})
+
+		url, err := s.PublicUrl(ctx, "public")
+		if err != nil || url.ID != urlID {
+			t.Errorf("PublicUrl() = %+v, %v, want url %d", url, err, urlID)
+		}
+	})
+
+	t.Run("alias of several users", func(t *testing.T) {
+		s := newStorage(t, false)
+		save(t, s, userID, "https://example.com", "shared", entities.UrlMeta{})
+		otherID := save(t, s, otherUserID, "https://example.org", "shared", entities.UrlMeta{})
+
+		_, err := s.PublicUrl(ctx, "shared")
+		wantErr(t, "PublicUrl() of ambiguous alias", err, storage.ErrAliasNotFound)
+
+		// removed url doesn't make the alias ambiguous
+		if _, err = s.RemoveUrl(ctx, userID, "shared"); err != nil {
+			t.Fatalf("RemoveUrl() error = %v", err)
+		}
+		url, err := s.PublicUrl(ctx, "shared")
+		if err != nil || url.ID != otherID {
+			t.Errorf("PublicUrl() = %+v, %v, want url %d", url, err, otherID)
+		}
+	})
+}
+
+func testUrlByURL(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t, true)
+
+	firstID := save(t, s, userID, "https://example.com/page", "first", entities.UrlMeta{})
+	secondID := save(t, s, userID, "https://example.com/page", "second", entities.UrlMeta{})
+	save(t, s, otherUserID, "https://example.com/other", "other", entities.UrlMeta{})
+
+	url, err := s.UrlByURL(ctx, userID, "https://example.com/page")
+	if err != nil || url.ID != firstID {
+		t.Errorf("UrlByURL() = %+v, %v, want the oldest url %d", url, err, firstID)
+	}
+
+	if _, err = s.RemoveUrl(ctx, userID, "first"); err != nil {
+		t.Fatalf("RemoveUrl() error = %v", err)
+	}
+	url, err = s.UrlByURL(ctx, userID, "https://example.com/page")
+	if err != nil || url.ID != secondID {
+		t.Errorf("UrlByURL() after removal = %+v, %v, want url %d", url, err, secondID)
+	}
+
+	_, err = s.UrlByURL(ctx, userID, "https://example.com/other")
+	wantErr(t, "UrlByURL() of url of other user", err, storage.ErrAliasNotFound)
+}
+
+func testUpdate(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		globalAliases bool
+		url           string
+		alias         string
+		title         string
+		want          error
+		wantURL       entities.URL
+	}{
+		{
+			name: "empty values", globalAliases: true,
+			wantURL: entities.URL{URL: "https://example.com", Alias: "old", Title: "title"},
+		},
+		{
+			name: "all values", globalAliases: true, url: "https://example.org", alias: "new", title: "new title",
+			wantURL: entities.URL{URL: "https://example.org", Alias: "new", Title: "new title"},
+		},
+		{
+			name: "current alias", globalAliases: true, alias: "old",
+			wantURL: entities.URL{URL: "https://example.com", Alias: "old", Title: "title"},
+		},
+		{name: "alias of the user", globalAliases: true, alias: "own", want: storage.ErrAliasExists},
+		{name: "alias of other user, global aliases", globalAliases: true, alias: "foreign", want: storage.ErrAliasExists},
+		{
+			name: "alias of other user, per user aliases", globalAliases: false, alias: "foreign",
+			wantURL: entities.URL{URL: "https://example.com", Alias: "foreign", Title: "title"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t, tt.globalAliases)
+			urlID := save(t, s, userID, "https://example.com", "old", entities.UrlMeta{Title: "title"})
+			save(t, s, userID, "https://example.com/own", "own", entities.UrlMeta{})
+			save(t, s, otherUserID, "https://example.com/foreign", "foreign", entities.UrlMeta{})
+
+			before, err := s.UrlByID(ctx, userID, urlID)
+			if err != nil {
+				t.Fatalf("UrlByID() error = %v", err)
+			}
+
+			err = s.UpdateUrl(ctx, userID, urlID, tt.url, tt.alias, entities.UrlMeta{Title: tt.title})
+			wantErr(t, "UpdateUrl()", err, tt.want)
+
+			url, err := s.UrlByID(ctx, userID, urlID)
+			if err != nil {
+				t.Fatalf("UrlByID() error = %v", err)
+			}
+			if tt.want != nil {
+				// failed update keeps the url as it was
+				tt.wantURL = before
+			}
+			if url.URL != tt.wantURL.URL || url.Alias != tt.wantURL.Alias || url.Title != tt.wantURL.Title {
+				t.Errorf("UrlByID() = %+v, want %+v", url, tt.wantURL)
+			}
+			if !url.CreatedAt.Equal(before.CreatedAt) || url.UpdatedAt.Before(before.UpdatedAt) {
+				t.Errorf("UrlByID() times = %v, %v, want created at %v and updated not before %v",
+					url.CreatedAt, url.UpdatedAt, before.CreatedAt, before.UpdatedAt)
+			}
+
+			if tt.want == nil && tt.alias != "" && tt.alias != "old" {
+				// old alias is free again, the new one points to the url
+				if _, err = s.Url(ctx, userID, "old"); !errors.Is(err, storage.ErrAliasNotFound) {
+					t.Errorf("Url() of old alias error = %v, want %v", err, storage.ErrAliasNotFound)
+				}
+				save(t, s, userID, "https://example.net", "old", entities.UrlMeta{})
+			}
+			if tt.url != "" {
+				byURL, err := s.UrlByURL(ctx, userID, tt.url)
+				if err != nil || byURL.ID != urlID {
+					t.Errorf("UrlByURL() of new url = %+v, %v, want url %d", byURL, err, urlID)
+				}
+			}
+		})
+	}
+}
+
+func testUpdateTags(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t, true)
+	urlID := save(t, s, userID, "https://example.com", "tagged", entities.UrlMeta{Tags: []string{"go", "blog"}})
+
+	tagged := func(tag string) bool {
+		t.Helper()
+
+		urls, err := s.UrlList(ctx, userID, entities.UrlFilter{Tag: tag}, nil, 10)
+		if err != nil {
+			t.Fatalf("UrlList() error = %v", err)
+		}
+		return len(urls) == 1 && urls[0].ID == urlID
+	}
+
+	// nil tags keep the current ones
+	if err := s.UpdateUrl(ctx, userID, urlID, "", "", entities.UrlMeta{Title: "title"}); err != nil {
+		t.Fatalf("UpdateUrl() error = %v", err)
+	}
+	if !tagged("go") || !tagged("blog") {
+		t.Errorf("tags are changed by update without tags")
+	}
+
+	if err := s.UpdateUrl(ctx, userID, urlID, "", "", entities.UrlMeta{Tags: []string{"news"}}); err != nil {
+		t.Fatalf("UpdateUrl() error = %v", err)
+	}
+	if tagged("go") || !tagged("news") {
+		t.Errorf("tags are not replaced by update")
+	}
+
+	if err := s.UpdateUrl(ctx, userID, urlID, "", "", entities.UrlMeta{Tags: []string{}}); err != nil {
+		t.Fatalf("UpdateUrl() error = %v", err)
+	}
+	if tagged("news") {
+		t.Errorf("tags are not cleared by update with empty tags")
+	}
+}
+
+func testRemoveAndRestore(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t, true)
+	urlID := save(t, s, userID, "https://example.com", "trash", entities.UrlMeta{})
+
+	_, err := s.RemoveUrl(ctx, otherUserID, "trash")
+	wantErr(t, "RemoveUrl() of other user", err, storage.ErrAliasNotFound)
+
+	removedID, err := s.RemoveUrl(ctx, userID, "trash")
+	if err != nil || removedID != urlID {
+		t.Fatalf("RemoveUrl() = %d, %v, want %d", removedID, err, urlID)
+	}
+
+	_, err = s.Url(ctx, userID, "trash")
+	wantErr(t, "Url() of removed url", err, storage.ErrAliasNotFound)
+	_, err = s.UrlByID(ctx, userID, urlID)
+	wantErr(t, "UrlByID() of removed url", err, storage.ErrAliasNotFound)
+	_, err = s.UrlByURL(ctx, userID, "https://example.com")
+	wantErr(t, "UrlByURL() of removed url", err, storage.ErrAliasNotFound)
+	_, err = s.PublicUrl(ctx, "trash")
+	wantErr(t, "PublicUrl() of removed url", err, storage.ErrAliasNotFound)
+	err = s.UpdateUrl(ctx, userID, urlID, "https://example.org", "", entities.UrlMeta{})
+	wantErr(t, "UpdateUrl() of removed url", err, storage.ErrAliasNotFound)
+	_, err = s.RemoveUrl(ctx, userID, "trash")
+	wantErr(t, "RemoveUrl() twice", err, storage.ErrAliasNotFound)
+
+	if urls, err := s.UrlList(ctx, userID, entities.UrlFilter{}, nil, 10); err != nil || len(urls) != 0 {
+		t.Errorf("UrlList() = %+v, %v, want removed url skipped", urls, err)
+	}
+
+	// the alias stays taken in the trash
+	_, err = s.SaveUrl(ctx, otherUserID, "https://example.org", "trash", entities.UrlMeta{})
+	wantErr(t, "SaveUrl() with alias in the trash", err, storage.ErrAliasExists)
+
+	_, err = s.RestoreUrl(ctx, otherUserID, "trash")
+	wantErr(t, "RestoreUrl() of other user", err, storage.ErrAliasNotFound)
+
+	restored, err := s.RestoreUrl(ctx, userID, "trash")
+	if err != nil || restored.ID != urlID || restored.URL != "https://example.com" || restored.Alias != "trash" {
+		t.Fatalf("RestoreUrl() = %+v, %v, want url %d", restored, err, urlID)
+	}
+
+	_, err = s.RestoreUrlByID(ctx, userID, urlID)
+	wantErr(t, "RestoreUrlByID() of active url", err, storage.ErrAliasNotFound)
+
+	if _, err = s.Url(ctx, userID, "trash"); err != nil {
+		t.Errorf("Url() of restored url error = %v", err)
+	}
+
+	// and once more by ID
+	if _, err = s.RemoveUrl(ctx, userID, "trash"); err != nil {
+		t.Fatalf("RemoveUrl() error = %v", err)
+	}
+	restored, err = s.RestoreUrlByID(ctx, userID, urlID)
+	if err != nil || restored.ID != urlID || restored.Alias != "trash" {
+		t.Errorf("RestoreUrlByID() = %+v, %v, want url %d", restored, err, urlID)
+	}
+}
+
+func testExpired(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t, true)
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	expiredID := save(t, s, userID, "https://example.com/expired", "expired", entities.UrlMeta{ExpiresAt: &past})
+	activeID := save(t, s, userID, "https://example.com/active", "active", entities.UrlMeta{ExpiresAt: &future})
+
+	_, err := s.Url(ctx, userID, "expired")
+	wantErr(t, "Url() of expired url", err, storage.ErrAliasNotFound)
+	_, err = s.UrlByID(ctx, userID, expiredID)
+	wantErr(t, "UrlByID() of expired url", err, storage.ErrAliasNotFound)
+	_, err = s.PublicUrl(ctx, "expired")
+	wantErr(t, "PublicUrl() of expired url", err, storage.ErrAliasNotFound)
+	err = s.UpdateUrl(ctx, userID, expiredID, "", "", entities.UrlMeta{ExpiresAt: &future})
+	wantErr(t, "UpdateUrl() of expired url", err, storage.ErrAliasNotFound)
+	_, err = s.RemoveUrl(ctx, userID, "expired")
+	wantErr(t, "RemoveUrl() of expired url", err, storage.ErrAliasNotFound)
+	_, err = s.SaveUrl(ctx, otherUserID, "https://example.org", "expired", entities.UrlMeta{})
+	wantErr(t, "SaveUrl() with alias of expired url", err, storage.ErrAliasExists)
+
+	// the cache keeps urls until they expire, so reads return the expiration
+	for call, read := range map[string]func() (entities.URL, error){
+		"Url":       func() (entities.URL, error) { return s.Url(ctx, userID, "active") },
+		"UrlByID":   func() (entities.URL, error) { return s.UrlByID(ctx, userID, activeID) },
+		"PublicUrl": func() (entities.URL, error) { return s.PublicUrl(ctx, "active") },
+	} {
+		got, err := read()
+		if err != nil {
+			t.Errorf("%s() of not yet expired url error = %v", call, err)
+			continue
+		}
+		// sqlite keeps whole seconds
+		if diff := got.ExpiresAt.Sub(future); diff < -time.Second || diff > time.Second {
+			t.Errorf("%s() ExpiresAt = %v, want %v", call, got.ExpiresAt, future)
+		}
+	}
+
+	save(t, s, userID, "https://example.com/permanent", "permanent", entities.UrlMeta{})
+	permanent, err := s.Url(ctx, userID, "permanent")
+	if err != nil || !permanent.ExpiresAt.IsZero() {
+		t.Errorf("Url() of permanent url = %v, %v, want zero ExpiresAt", permanent.ExpiresAt, err)
+	}
+
+	if err = s.UpdateUrl(ctx, userID, activeID, "", "", entities.UrlMeta{ExpiresAt: &past}); err != nil {
+		t.Fatalf("UpdateUrl() expiring the url error = %v", err)
+	}
+	_, err = s.Url(ctx, userID, "active")
+	wantErr(t, "Url() of url expired by update", err, storage.ErrAliasNotFound)
+}
+
+func testPagination(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t, true)
+
+	// aliases are saved out of their order, so orders by alias and by
+	// creation differ. Upper case goes before lower case byte by byte
+	aliases := []string{"delta", "Bravo", "echo", "alpha", "charlie", "foxtrot", "bravo"}
+	ids := make(map[string]int64, len(aliases))
+	for _, alias := range aliases {
+		ids[alias] = save(t, s, userID, "https://example.com/"+alias, alias, entities.UrlMeta{})
+	}
+	save(t, s, otherUserID, "https://example.com/other", "other", entities.UrlMeta{})
+	save(t, s, userID, "https://example.com/removed", "removed", entities.UrlMeta{})
+	if _, err := s.RemoveUrl(ctx, userID, "removed"); err != nil {
+		t.Fatalf("RemoveUrl() error = %v", err)
+	}
+
+	byAlias := slices.Clone(aliases)
+	slices.Sort(byAlias)
+
+	tests := []struct {
+		name   string
+		filter entities.UrlFilter
+		want   []string
+	}{
+		{name: "created", filter: entities.UrlFilter{}, want: aliases},
+		{name: "created descending", filter: entities.UrlFilter{Descending: true}, want: reversed(aliases)},
+		{name: "alias", filter: entities.UrlFilter{SortBy: entities.SortByAlias}, want: byAlias},
+		{name: "alias descending", filter: entities.UrlFilter{SortBy: entities.SortByAlias, Descending: true}, want: reversed(byAlias)},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 3, 100} {
+			t.Run(fmt.Sprintf("%s by %d", tt.name, limit), func(t *testing.T) {
+				var (
+					got   []string
+					after *entities.Cursor
+				)
+				for page := 0; page <= len(aliases); page++ {
+					urls, err := s.UrlList(ctx, userID, tt.filter, after, limit)
+					if err != nil {
+						t.Fatalf("UrlList() error = %v", err)
+					}
+					if len(urls) > limit {
+						t.Fatalf("UrlList() returned %d urls, want at most %d", len(urls), limit)
+					}
+					if len(urls) == 0 {
+						break
+					}
+
+					for _, url := range urls {
+						if url.ID != ids[url.Alias] {
+							t.Errorf("UrlList() returned url %+v, want ID %d", url, ids[url.Alias])
+						}
+						got = append(got, url.Alias)
+					}
+					last := urls[len(urls)-1]
+					after = &entities.Cursor{CreatedAt: last.CreatedAt, UpdatedAt: last.UpdatedAt, Alias: last.Alias, ID: last.ID}
+				}
+
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("UrlList() pages = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func reversed(values []string) []string {
+	values = slices.Clone(values)
+	slices.Reverse(values)
+	return values
+}
+
+func testFilters(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t, true)
+
+	save(t, s, userID, "https://go.dev/blog", "go_blog", entities.UrlMeta{Title: "The Go Blog", Tags: []string{"go"}})
+	save(t, s, userID, "https://go.dev/doc", "goXdoc", entities.UrlMeta{Title: "Documentation", Tags: []string{"go", "docs"}})
+	save(t, s, userID, "https://www.example.com/news", "news", entities.UrlMeta{Title: "Daily news"})
+	save(t, s, otherUserID, "https://go.dev/play", "go_play", entities.UrlMeta{Title: "Playground", Tags: []string{"go"}})
+
+	tests := []struct {
+		name   string
+		filter entities.UrlFilter
+		want   []string
+	}{
+		{name: "domain", filter: entities.UrlFilter{Domain: "GO.dev"}, want: []string{"go_blog", "goXdoc"}},
+		{name: "alias prefix", filter: entities.UrlFilter{AliasPrefix: "go"}, want: []string{"go_blog", "goXdoc"}},
+		{name: "alias prefix is literal", filter: entities.UrlFilter{AliasPrefix: "go_"}, want: []string{"go_blog"}},
+		{name: "tag", filter: entities.UrlFilter{Tag: "docs"}, want: []string{"goXdoc"}},
+		{name: "query in title", filter: entities.UrlFilter{Query: "blog"}, want: []string{"go_blog"}},
+		{name: "query in url", filter: entities.UrlFilter{Query: "example"}, want: []string{"news"}},
+		{name: "query of several words", filter: entities.UrlFilter{Query: "go documentation"}, want: []string{"goXdoc"}},
+		{name: "query matching nothing", filter: entities.UrlFilter{Query: "blog news"}},
+		{name: "combined", filter: entities.UrlFilter{Domain: "go.dev", Tag: "go", Query: "doc"}, want: []string{"goXdoc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, err := s.UrlList(ctx, userID, tt.filter, nil, 10)
+			if err != nil {
+				t.Fatalf("UrlList() error = %v", err)
+			}
+
+			var got []string
+			for _, url := range urls {
+				got = append(got, url.Alias)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("UrlList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testConcurrency(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+
+	// race runs fn in workers at once and returns their errors
+	race := func(fn func(worker int) error) []error {
+		var (
+			wg    sync.WaitGroup
+			start = make(chan struct{})
+			errs  = make([]error, workers)
+		)
+		for worker := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				errs[worker] = fn(worker)
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		return errs
+	}
+
+	// wantOne fails the test unless exactly one call succeeded and others
+	// failed with target
+	wantOne := func(t *testing.T, call string, errs []error, target error) {
+		t.Helper()
+
+		succeeded := 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				succeeded++
+			case !errors.Is(err, target):
+				t.Errorf("%s error = %v, want %v", call, err, target)
+			}
+		}
+		if succeeded != 1 {
+			t.Errorf("%s succeeded %d times, want once", call, succeeded)
+		}
+	}
+
+	t.Run("same alias", func(t *testing.T) {
+		s := newStorage(t, true)
+
+		errs := race(func(worker int) error {
+			_, err := s.SaveUrl(ctx, int64(worker+1), fmt.Sprintf("https://example.com/%d", worker), "race", entities.UrlMeta{})
+			return err
+		})
+		wantOne(t, "SaveUrl()", errs, storage.ErrAliasExists)
+
+		if _, err := s.PublicUrl(ctx, "race"); err != nil {
+			t.Errorf("PublicUrl() error = %v", err)
+		}
+	})
+
+	t.Run("distinct aliases", func(t *testing.T) {
+		s := newStorage(t, true)
+
+		ids := make([]int64, workers)
+		errs := race(func(worker int) (err error) {
+			ids[worker], err = s.SaveUrl(ctx, userID, "https://example.com", fmt.Sprintf("alias%d", worker), entities.UrlMeta{})
+			return err
+		})
+		for _, err := range errs {
+			if err != nil {
+				t.Fatalf("SaveUrl() error = %v", err)
+			}
+		}
+
+		slices.Sort(ids)
+		if len(slices.Compact(ids)) != workers {
+			t.Errorf("SaveUrl() returned IDs %v, want %d distinct", ids, workers)
+		}
+
+		urls, err := s.UrlList(ctx, userID, entities.UrlFilter{}, nil, workers+1)
+		if err != nil || len(urls) != workers {
+			t.Errorf("UrlList() returned %d urls, %v, want %d", len(urls), err, workers)
+		}
+	})
+
+	t.Run("update to same alias", func(t *testing.T) {
+		s := newStorage(t, true)
+
+		ids := make([]int64, workers)
+		for worker := range workers {
+			ids[worker] = save(t, s, userID, "https://example.com", fmt.Sprintf("alias%d", worker), entities.UrlMeta{})
+		}
+
+		errs := race(func(worker int) error {
+			return s.UpdateUrl(ctx, userID, ids[worker], "", "race", entities.UrlMeta{})
+		})
+		wantOne(t, "UpdateUrl()", errs, storage.ErrAliasExists)
+	})
+
+	t.Run("remove and restore", func(t *testing.T) {
+		s := newStorage(t, true)
+		urlID := save(t, s, userID, "https://example.com", "trash", entities.UrlMeta{})
+
+		errs := race(func(int) error {
+			_, err := s.RemoveUrl(ctx, userID, "trash")
+			return err
+		})
+		wantOne(t, "RemoveUrl()", errs, storage.ErrAliasNotFound)
+
+		errs = race(func(int) error {
+			_, err := s.RestoreUrlByID(ctx, userID, urlID)
+			return err
+		})
+		wantOne(t, "RestoreUrlByID()", errs, storage.ErrAliasNotFound)
+	})
+}
+
+func testContextCancellation(t *testing.T, newStorage NewStorage) {
+	s := newStorage(t, true)
+	urlID := save(t, s, userID, "https://example.com", "kept", entities.UrlMeta{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{name: "SaveUrl", call: func() error {
+			_, err := s.SaveUrl(ctx, userID, "https://example.org", "canceled", entities.UrlMeta{})
+			return err
+		}},
+		{name: "Url", call: func() error { _, err := s.Url(ctx, userID, "kept"); return err }},
+		{name: "UrlByID", call: func() error { _, err := s.UrlByID(ctx, userID, urlID); return err }},
+		{name: "UrlByURL", call: func() error { _, err := s.UrlByURL(ctx, userID, "https://example.com"); return err }},
+		{name: "PublicUrl", call: func() error { _, err := s.PublicUrl(ctx, "kept"); return err }},
+		{name: "UrlList", call: func() error {
+			_, err := s.UrlList(ctx, userID, entities.UrlFilter{}, nil, 10)
+			return err
+		}},
+		{name: "UpdateUrl", call: func() error {
+			return s.UpdateUrl(ctx, userID, urlID, "https://example.org", "changed", entities.UrlMeta{})
+		}},
+		{name: "RemoveUrl", call: func() error { _, err := s.RemoveUrl(ctx, userID, "kept"); return err }},
+		{name: "RestoreUrl", call: func() error { _, err := s.RestoreUrl(ctx, userID, "kept"); return err }},
+		{name: "RestoreUrlByID", call: func() error { _, err := s.RestoreUrlByID(ctx, userID, urlID); return err }},
+	}
+	for _, tt := range calls {
+		t.Run(tt.name, func(t *testing.T) {
+			wantErr(t, tt.name+"()", tt.call(), context.Canceled)
+		})
+	}
+
+	// none of the canceled calls changed anything
+	url, err := s.UrlByID(context.Background(), userID, urlID)
+	if err != nil || url.URL != "https://example.com" || url.Alias != "kept" {
+		t.Errorf("UrlByID() = %+v, %v, want the saved url unchanged", url, err)
+	}
+	_, err = s.Url(context.Background(), userID, "canceled")
+	wantErr(t, "Url() of url saved with canceled context", err, storage.ErrAliasNotFound)
+}
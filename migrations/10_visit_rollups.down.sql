@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS visit_user_agents_hourly;
+DROP TABLE IF EXISTS visit_referrers_hourly;
+DROP TABLE IF EXISTS visit_visitors_hourly;
+DROP TABLE IF EXISTS visit_rollups_hourly;
@@ -0,0 +1,47 @@
+-- visits counted per url and hour, analytics reads these instead of visits
+CREATE TABLE IF NOT EXISTS visit_rollups_hourly
+(
+    url_id INTEGER NOT NULL,
+    hour TIMESTAMP NOT NULL,
+    visits INTEGER NOT NULL,
+    PRIMARY KEY (url_id, hour)
+) WITHOUT ROWID;
+
+-- distinct visitors per url and hour, so unique visitors of any range of hours can be counted
+CREATE TABLE IF NOT EXISTS visit_visitors_hourly
+(
+    url_id INTEGER NOT NULL,
+    hour TIMESTAMP NOT NULL,
+    ip_hash CHAR(64) NOT NULL,
+    PRIMARY KEY (url_id, hour, ip_hash)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS visit_referrers_hourly
+(
+    url_id INTEGER NOT NULL,
+    hour TIMESTAMP NOT NULL,
+    referrer TEXT NOT NULL,
+    visits INTEGER NOT NULL,
+    PRIMARY KEY (url_id, hour, referrer)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS visit_user_agents_hourly
+(
+    url_id INTEGER NOT NULL,
+    hour TIMESTAMP NOT NULL,
+    user_agent TEXT NOT NULL,
+    visits INTEGER NOT NULL,
+    PRIMARY KEY (url_id, hour, user_agent)
+) WITHOUT ROWID;
+
+INSERT OR IGNORE INTO visit_rollups_hourly (url_id, hour, visits)
+SELECT url_id, strftime('%Y-%m-%d %H:00:00', visited_at), COUNT(*) FROM visits GROUP BY 1, 2;
+
+INSERT OR IGNORE INTO visit_visitors_hourly (url_id, hour, ip_hash)
+SELECT DISTINCT url_id, strftime('%Y-%m-%d %H:00:00', visited_at), ip_hash FROM visits WHERE ip_hash != '';
+
+INSERT OR IGNORE INTO visit_referrers_hourly (url_id, hour, referrer, visits)
+SELECT url_id, strftime('%Y-%m-%d %H:00:00', visited_at), referrer, COUNT(*) FROM visits GROUP BY 1, 2, 3;
+
+INSERT OR IGNORE INTO visit_user_agents_hourly (url_id, hour, user_agent, visits)
+SELECT url_id, strftime('%Y-%m-%d %H:00:00', visited_at), user_agent, COUNT(*) FROM visits GROUP BY 1, 2, 3;
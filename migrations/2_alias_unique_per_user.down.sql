@@ -0,0 +1,40 @@
+-- Aliases become unique across all users again. When users share an alias
+-- there is no way to keep every link reachable by its alias, so the rollback
+-- fails instead of dropping or renaming links of some users. Resolve the
+-- duplicates listed by
+--   SELECT alias, COUNT(*) FROM urls GROUP BY alias HAVING COUNT(*) > 1;
+-- and run the rollback again. The migration runs in a transaction, so
+-- nothing is changed when it fails, but the version is left dirty and must
+-- be forced back to 2 first
+CREATE TEMP TABLE alias_duplicates (count INTEGER NOT NULL);
+
+CREATE TEMP TRIGGER alias_duplicates_abort BEFORE INSERT ON alias_duplicates
+WHEN NEW.count > 0
+BEGIN
+    SELECT RAISE(ABORT, 'aliases are shared by several users, make them unique before the rollback');
+END;
+
+INSERT INTO alias_duplicates (count)
+SELECT COUNT(*) FROM (SELECT alias FROM urls GROUP BY alias HAVING COUNT(*) > 1);
+
+DROP TRIGGER alias_duplicates_abort;
+DROP TABLE alias_duplicates;
+
+CREATE TABLE IF NOT EXISTS urls_old
+(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    alias VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO urls_old (id, user_id, url, alias, created_at, updated_at)
+SELECT id, user_id, url, alias, created_at, updated_at FROM urls;
+
+DROP TABLE urls;
+ALTER TABLE urls_old RENAME TO urls;
+
+CREATE INDEX IF NOT EXISTS idx_user_id ON urls (user_id);
+CREATE INDEX IF NOT EXISTS idx_alias ON urls (alias);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS urls_new
+(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    alias VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, alias)
+);
+
+INSERT INTO urls_new (id, user_id, url, alias, created_at, updated_at)
+SELECT id, user_id, url, alias, created_at, updated_at FROM urls;
+
+DROP TABLE urls;
+ALTER TABLE urls_new RENAME TO urls;
+
+CREATE INDEX IF NOT EXISTS idx_user_id ON urls (user_id);
+CREATE INDEX IF NOT EXISTS idx_alias ON urls (alias);
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_user_id_created_at_id ON urls (user_id, created_at, id);
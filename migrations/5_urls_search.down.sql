@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS urls_fts_update;
+DROP TRIGGER IF EXISTS urls_fts_delete;
+DROP TRIGGER IF EXISTS urls_fts_insert;
+DROP TABLE IF EXISTS urls_fts;
+DROP TABLE IF EXISTS url_tags;
+DROP INDEX IF EXISTS idx_user_id_updated_at_id;
+DROP INDEX IF EXISTS idx_user_id_domain;
+ALTER TABLE urls DROP COLUMN domain;
+ALTER TABLE urls DROP COLUMN title;
@@ -0,0 +1,41 @@
+ALTER TABLE urls ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '';
+
+-- best effort backfill, new rows get the domain from the application
+UPDATE urls SET domain = lower(
+    CASE
+        WHEN instr(substr(url, instr(url, '://') + 3), '/') > 0
+            THEN substr(substr(url, instr(url, '://') + 3), 1, instr(substr(url, instr(url, '://') + 3), '/') - 1)
+        ELSE substr(url, instr(url, '://') + 3)
+    END
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_id_domain ON urls (user_id, domain);
+CREATE INDEX IF NOT EXISTS idx_user_id_updated_at_id ON urls (user_id, updated_at, id);
+
+CREATE TABLE IF NOT EXISTS url_tags
+(
+    url_id INTEGER NOT NULL,
+    tag VARCHAR(50) NOT NULL,
+    PRIMARY KEY (url_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_tags_tag ON url_tags (tag);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS urls_fts USING fts5(url, title, content = 'urls', content_rowid = 'id');
+
+INSERT INTO urls_fts (rowid, url, title) SELECT id, url, title FROM urls;
+
+CREATE TRIGGER IF NOT EXISTS urls_fts_insert AFTER INSERT ON urls BEGIN
+    INSERT INTO urls_fts (rowid, url, title) VALUES (new.id, new.url, new.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS urls_fts_delete AFTER DELETE ON urls BEGIN
+    INSERT INTO urls_fts (urls_fts, rowid, url, title) VALUES ('delete', old.id, old.url, old.title);
+    DELETE FROM url_tags WHERE url_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS urls_fts_update AFTER UPDATE OF url, title ON urls BEGIN
+    INSERT INTO urls_fts (urls_fts, rowid, url, title) VALUES ('delete', old.id, old.url, old.title);
+    INSERT INTO urls_fts (rowid, url, title) VALUES (new.id, new.url, new.title);
+END;
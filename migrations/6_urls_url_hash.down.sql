@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_user_id_url_hash;
+ALTER TABLE urls DROP COLUMN url_hash;
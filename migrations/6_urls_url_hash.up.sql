@@ -0,0 +1,5 @@
+-- SHA-256 of the normalized url, set by the application. SQLite can't compute it,
+-- so existing rows are left NULL and hashed by the application on start
+ALTER TABLE urls ADD COLUMN url_hash CHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_user_id_url_hash ON urls (user_id, url_hash);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_urls_archive_user_id;
+DROP TABLE IF EXISTS urls_archive;
+DROP INDEX IF EXISTS idx_expires_at;
+ALTER TABLE urls DROP COLUMN expires_at;
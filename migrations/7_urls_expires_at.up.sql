@@ -0,0 +1,19 @@
+ALTER TABLE urls ADD COLUMN expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_expires_at ON urls (expires_at) WHERE expires_at IS NOT NULL;
+
+-- expired urls moved away by the janitor in archive mode
+CREATE TABLE IF NOT EXISTS urls_archive
+(
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    alias VARCHAR(50) NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_urls_archive_user_id ON urls_archive (user_id);
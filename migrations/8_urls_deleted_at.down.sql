@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_deleted_at;
+ALTER TABLE urls DROP COLUMN deleted_at;
@@ -0,0 +1,4 @@
+-- removed urls stay in the trash with their aliases held until the janitor purges them
+ALTER TABLE urls ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_deleted_at ON urls (deleted_at) WHERE deleted_at IS NOT NULL;
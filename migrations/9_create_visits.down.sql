@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_visits_url_id_visited_at;
+DROP TABLE IF EXISTS visits;
@@ -0,0 +1,14 @@
+-- visits are kept after their url is removed, so there is no foreign key
+CREATE TABLE IF NOT EXISTS visits
+(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url_id INTEGER NOT NULL,
+    alias VARCHAR(50) NOT NULL,
+    visited_at TIMESTAMP NOT NULL,
+    referrer TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_hash CHAR(64) NOT NULL DEFAULT '',
+    country CHAR(2) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_visits_url_id_visited_at ON visits (url_id, visited_at);
@@ -0,0 +1,55 @@
+CREATE TABLE IF NOT EXISTS urls
+(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    url TEXT NOT NULL,
+    alias VARCHAR(50) NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    domain VARCHAR(255) NOT NULL DEFAULT '',
+    -- SHA-256 of the normalized url, set by the application
+    url_hash CHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ,
+    -- removed urls stay in the trash with their aliases held until the janitor purges them
+    deleted_at TIMESTAMPTZ,
+    -- words of the url and its title, punctuation of the url separates words
+    search TSVECTOR GENERATED ALWAYS AS (
+        to_tsvector('simple', regexp_replace(url, '[^[:alnum:]]+', ' ', 'g') || ' ' || title)
+    ) STORED,
+    UNIQUE (user_id, alias)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alias ON urls (alias);
+CREATE INDEX IF NOT EXISTS idx_user_id_created_at_id ON urls (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_user_id_updated_at_id ON urls (user_id, updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_user_id_domain ON urls (user_id, domain);
+CREATE INDEX IF NOT EXISTS idx_user_id_url_hash ON urls (user_id, url_hash);
+CREATE INDEX IF NOT EXISTS idx_expires_at ON urls (expires_at) WHERE expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_deleted_at ON urls (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_search ON urls USING GIN (search);
+
+CREATE TABLE IF NOT EXISTS url_tags
+(
+    url_id BIGINT NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+    tag VARCHAR(50) NOT NULL,
+    PRIMARY KEY (url_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_tags_tag ON url_tags (tag);
+
+-- expired urls moved away by the janitor in archive mode
+CREATE TABLE IF NOT EXISTS urls_archive
+(
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    url TEXT NOT NULL,
+    alias VARCHAR(50) NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_urls_archive_user_id ON urls_archive (user_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS visit_user_agents_hourly;
+DROP TABLE IF EXISTS visit_referrers_hourly;
+DROP TABLE IF EXISTS visit_visitors_hourly;
+DROP TABLE IF EXISTS visit_rollups_hourly;
+DROP TABLE IF EXISTS visits;
@@ -0,0 +1,50 @@
+-- visits are kept after their url is removed, so there is no foreign key
+CREATE TABLE IF NOT EXISTS visits
+(
+    id BIGSERIAL PRIMARY KEY,
+    url_id BIGINT NOT NULL,
+    alias VARCHAR(50) NOT NULL,
+    visited_at TIMESTAMPTZ NOT NULL,
+    referrer TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_hash CHAR(64) NOT NULL DEFAULT '',
+    country CHAR(2) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_visits_url_id_visited_at ON visits (url_id, visited_at);
+
+-- visits counted per url and hour, analytics reads these instead of visits
+CREATE TABLE IF NOT EXISTS visit_rollups_hourly
+(
+    url_id BIGINT NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    visits BIGINT NOT NULL,
+    PRIMARY KEY (url_id, hour)
+);
+
+-- distinct visitors per url and hour, so unique visitors of any range of hours can be counted
+CREATE TABLE IF NOT EXISTS visit_visitors_hourly
+(
+    url_id BIGINT NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    ip_hash CHAR(64) NOT NULL,
+    PRIMARY KEY (url_id, hour, ip_hash)
+);
+
+CREATE TABLE IF NOT EXISTS visit_referrers_hourly
+(
+    url_id BIGINT NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    referrer TEXT NOT NULL,
+    visits BIGINT NOT NULL,
+    PRIMARY KEY (url_id, hour, referrer)
+);
+
+CREATE TABLE IF NOT EXISTS visit_user_agents_hourly
+(
+    url_id BIGINT NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    user_agent TEXT NOT NULL,
+    visits BIGINT NOT NULL,
+    PRIMARY KEY (url_id, hour, user_agent)
+);
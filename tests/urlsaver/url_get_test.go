@@ -1 +1,37 @@
 package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+)
+
+func TestGet(t *testing.T) {
+	saver := newUrlSaver(t)
+	urlID, _ := save(t, saver, userID, "https://example.com/page", "page")
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		alias   string
+		wantErr error
+	}{
+		{name: "own url", ctx: userContext(userID), alias: "page"},
+		{name: "redirect without user", ctx: context.Background(), alias: "page"},
+		{name: "url of other user", ctx: userContext(otherUserID), alias: "page", wantErr: urlsaver.ErrAliasNotFound},
+		{name: "unknown alias", ctx: userContext(userID), alias: "unknown", wantErr: urlsaver.ErrAliasNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, alias, gotID, err := saver.Get(tt.ctx, tt.alias)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (url != "https://example.com/page" || alias != "page" || gotID != urlID) {
+				t.Errorf("Get() = %q, %q, %d, want url %d", url, alias, gotID, urlID)
+			}
+		})
+	}
+}
@@ -1 +1,55 @@
 package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+)
+
+func TestRemoveByAlias(t *testing.T) {
+	saver := newUrlSaver(t)
+	ctx := userContext(userID)
+	urlID, _ := save(t, saver, userID, "https://example.com", "trash")
+
+	if _, _, err := saver.RemoveByAlias(userContext(otherUserID), "trash"); !errors.Is(err, urlsaver.ErrAliasNotFound) {
+		t.Errorf("RemoveByAlias() of other user error = %v, want %v", err, urlsaver.ErrAliasNotFound)
+	}
+
+	ok, removedID, err := saver.RemoveByAlias(ctx, "trash")
+	if err != nil || !ok || removedID != urlID {
+		t.Fatalf("RemoveByAlias() = %t, %d, %v, want url %d removed", ok, removedID, err, urlID)
+	}
+
+	if _, _, _, err = saver.Get(ctx, "trash"); !errors.Is(err, urlsaver.ErrAliasNotFound) {
+		t.Errorf("Get() of removed url error = %v, want %v", err, urlsaver.ErrAliasNotFound)
+	}
+	if _, _, err = saver.RemoveByAlias(ctx, "trash"); !errors.Is(err, urlsaver.ErrAliasNotFound) {
+		t.Errorf("RemoveByAlias() twice error = %v, want %v", err, urlsaver.ErrAliasNotFound)
+	}
+	if _, _, err = saver.RemoveByID(ctx, urlID); !errors.Is(err, urlsaver.ErrAliasNotFound) {
+		t.Errorf("RemoveByID() of removed url error = %v, want %v", err, urlsaver.ErrAliasNotFound)
+	}
+}
+
+func TestRemoveAndRestore(t *testing.T) {
+	saver := newUrlSaver(t)
+	ctx := userContext(userID)
+	urlID, _ := save(t, saver, userID, "https://example.com", "trash")
+
+	if _, _, err := saver.RemoveByID(ctx, urlID); err != nil {
+		t.Fatalf("RemoveByID() error = %v", err)
+	}
+
+	url, alias, restoredID, err := saver.RestoreByAlias(ctx, "trash")
+	if err != nil || url != "https://example.com" || alias != "trash" || restoredID != urlID {
+		t.Fatalf("RestoreByAlias() = %q, %q, %d, %v, want url %d", url, alias, restoredID, err, urlID)
+	}
+
+	if _, _, _, err = saver.RestoreByID(ctx, urlID); !errors.Is(err, urlsaver.ErrAliasNotFound) {
+		t.Errorf("RestoreByID() of active url error = %v, want %v", err, urlsaver.ErrAliasNotFound)
+	}
+	if _, _, _, err = saver.Get(ctx, "trash"); err != nil {
+		t.Errorf("Get() of restored url error = %v", err)
+	}
+}
@@ -1 +1,237 @@
 package tests
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	urlshortener "github.com/nhassl3/url-saver/internals/clients/urlshortener/http"
+	localshortener "github.com/nhassl3/url-saver/internals/clients/urlshortener/local"
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/domain/services/urlnorm"
+	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+	"github.com/nhassl3/url-saver/internals/lib/aliasgen"
+	"github.com/nhassl3/url-saver/internals/lib/pagetoken"
+	"github.com/nhassl3/url-saver/internals/lib/userctx"
+	"github.com/nhassl3/url-saver/internals/storage"
+	"github.com/nhassl3/url-saver/internals/storage/memory"
+)
+
+const (
+	userID      = 1
+	otherUserID = 2
+)
+
+// newUrlSaver returns the service over empty memory storage with aliases
+// unique across all users
+func newUrlSaver(t *testing.T) *urlsaver.UrlSaver {
+	t.Helper()
+
+	return newUrlSaverWith(t, nil, nil)
+}
+
+// newUrlSaverWith returns the service like newUrlSaver does, but with the
+// given shortener and alias generator. Nil shortener is the in-process one,
+// nil generator makes random aliases
+func newUrlSaverWith(t *testing.T, shortener urlsaver.Shortener, aliases urlsaver.AliasGenerator) *urlsaver.UrlSaver {
+	t.Helper()
+
+	log := slog.New(slog.DiscardHandler)
+
+	if aliases == nil {
+		var err error
+		if aliases, err = aliasgen.NewRandom("abcdefghijklmnopqrstuvwxyz0123456789", 8); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if shortener == nil {
+		shortener = localshortener.NewShortener(log, aliases)
+	}
+
+	s := memory.NewStorage(true)
+
+	return urlsaver.NewUrlSaver(
+		log, s, s, s, shortener,
+		urlnorm.NewNormalizer([]string{"http", "https"}, 2048, false),
+		pagetoken.NewCodec([]byte("secret")), aliases,
+		urlsaver.Options{MaxPageSize: 100, AliasRetries: 3, ReservedAliases: []string{"admin"}},
+	)
+}
+
+func userContext(userID int64) context.Context {
+	return userctx.WithUserID(context.Background(), userID)
+}
+
+// save saves the url of the user and fails the test on error
+func save(t *testing.T, saver *urlsaver.UrlSaver, userID int64, url, alias string) (urlID int64, aliasRes string) {
+	t.Helper()
+
+	urlID, aliasRes, err := saver.Save(userContext(userID), url, alias, entities.UrlMeta{}, nil)
+	if err != nil {
+		t.Fatalf("Save(%q, %q): %v", url, alias, err)
+	}
+
+	return urlID, aliasRes
+}
+
+func TestSave(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		url     string
+		alias   string
+		meta    entities.UrlMeta
+		wantErr error
+	}{
+		{name: "with alias", ctx: userContext(userID), url: "https://example.com/new", alias: "new"},
+		{name: "taken by the user", ctx: userContext(userID), url: "https://example.com/new", alias: "taken", wantErr: urlsaver.ErrAliasExists},
+		{name: "taken by other user", ctx: userContext(otherUserID), url: "https://example.com/new", alias: "taken", wantErr: urlsaver.ErrAliasExists},
+		{name: "reserved alias", ctx: userContext(userID), url: "https://example.com/new", alias: "Admin", wantErr: urlsaver.ErrAliasReserved},
+		{name: "invalid alias", ctx: userContext(userID), url: "https://example.com/new", alias: "a b", wantErr: urlsaver.ErrAliasInvalid},
+		{name: "invalid url", ctx: userContext(userID), url: "ftp://example.com", alias: "ftp", wantErr: storage.ErrUrlIsInvalid},
+		{name: "expired", ctx: userContext(userID), url: "https://example.com/new", alias: "expired", meta: entities.UrlMeta{ExpiresAt: &past}, wantErr: urlsaver.ErrInvalidExpiration},
+		{name: "unauthenticated", ctx: context.Background(), url: "https://example.com/new", alias: "anonymous", wantErr: urlsaver.ErrUnauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saver := newUrlSaver(t)
+			save(t, saver, userID, "https://example.com/taken", "taken")
+
+			urlID, alias, err := saver.Save(tt.ctx, tt.url, tt.alias, tt.meta, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Save() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (urlID == 0 || alias != tt.alias) {
+				t.Errorf("Save() = %d, %q, want new url with alias %q", urlID, alias, tt.alias)
+			}
+		})
+	}
+}
+
+func TestSaveGeneratesAlias(t *testing.T) {
+	saver := newUrlSaver(t)
+
+	urlID, alias := save(t, saver, userID, "https://example.com", "")
+	if alias == "" {
+		t.Fatal("Save() returned empty alias")
+	}
+
+	url, _, gotID, err := saver.Get(userContext(userID), alias)
+	if err != nil || url != "https://example.com" || gotID != urlID {
+		t.Errorf("Get(%q) = %q, %d, %v, want the saved url", alias, url, gotID, err)
+	}
+}
+
+// scriptedAliases generates the given aliases in order, then fails the test
+type scriptedAliases struct {
+	t       *testing.T
+	aliases []string
+}
+
+func (s *scriptedAliases) Generate() (string, error) {
+	if len(s.aliases) == 0 {
+		s.t.Fatal("Generate() called after the last scripted alias")
+	}
+	alias := s.aliases[0]
+	s.aliases = s.aliases[1:]
+
+	return alias, nil
+}
+
+func TestSaveRetriesGeneratedAlias(t *testing.T) {
+	tests := []struct {
+		name      string
+		generated []string
+		wantAlias string
+		wantErr   error
+	}{
+		{name: "free", generated: []string{"free"}, wantAlias: "free"},
+		{name: "taken", generated: []string{"taken", "free"}, wantAlias: "free"},
+		{name: "reserved", generated: []string{"admin", "free"}, wantAlias: "free"},
+		{name: "reserved in other case", generated: []string{"Admin", "taken", "free"}, wantAlias: "free"},
+		// the saver is built with 3 retries, so the 4th candidate is the last one
+		{name: "last attempt", generated: []string{"taken", "admin", "taken", "free"}, wantAlias: "free"},
+		{name: "retries exhausted", generated: []string{"taken", "admin", "taken", "admin"}, wantErr: urlsaver.ErrAliasGeneration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aliases := &scriptedAliases{t: t, aliases: tt.generated}
+			saver := newUrlSaverWith(t, nil, aliases)
+
+			// the url of another user takes the alias, aliases are global
+			aliases.aliases = append([]string{"taken"}, aliases.aliases...)
+			save(t, saver, otherUserID, "https://example.com/taken", "")
+
+			urlID, alias, err := saver.Save(userContext(userID), "https://example.com", "", entities.UrlMeta{}, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Save() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (urlID == 0 || alias != tt.wantAlias) {
+				t.Errorf("Save() = %d, %q, want new url with alias %q", urlID, alias, tt.wantAlias)
+			}
+			if len(aliases.aliases) != 0 {
+				t.Errorf("aliases %v were not generated", aliases.aliases)
+			}
+		})
+	}
+}
+
+func TestSaveDedupe(t *testing.T) {
+	saver := newUrlSaver(t)
+	dedupe := true
+
+	urlID, alias := save(t, saver, userID, "https://example.com/page", "first")
+
+	gotID, gotAlias, err := saver.Save(userContext(userID), "HTTPS://EXAMPLE.com/page/", "", entities.UrlMeta{}, &dedupe)
+	if err != nil || gotID != urlID || gotAlias != alias {
+		t.Errorf("Save() with dedupe = %d, %q, %v, want %d, %q", gotID, gotAlias, err, urlID, alias)
+	}
+
+	otherID, _, err := saver.Save(userContext(otherUserID), "https://example.com/page", "", entities.UrlMeta{}, &dedupe)
+	if err != nil || otherID == urlID {
+		t.Errorf("Save() of other user with dedupe = %d, %v, want new url", otherID, err)
+	}
+
+	// the requested alias is never dropped for the saved one
+	gotID, gotAlias, err = saver.Save(userContext(userID), "https://example.com/page", "second", entities.UrlMeta{}, &dedupe)
+	if err != nil || gotID == urlID || gotAlias != "second" {
+		t.Errorf("Save() with dedupe and other alias = %d, %q, %v, want new url with alias %q", gotID, gotAlias, err, "second")
+	}
+	if url, _, _, err := saver.Get(userContext(userID), "second"); err != nil || url != "https://example.com/page" {
+		t.Errorf("Get(%q) = %q, %v, want the saved url", "second", url, err)
+	}
+
+	_, _, err = saver.Save(userContext(userID), "https://example.com/page", "first", entities.UrlMeta{}, &dedupe)
+	if !errors.Is(err, urlsaver.ErrAliasExists) {
+		t.Errorf("Save() with dedupe and the saved alias error = %v, want %v", err, urlsaver.ErrAliasExists)
+	}
+}
+
+// rewritingShortener answers with its own form of the url, like a remote
+// shortener may do
+type rewritingShortener struct{}
+
+func (rewritingShortener) ShortenURL(_ context.Context, url, alias string) (*urlshortener.ShortenResponse, error) {
+	return &urlshortener.ShortenResponse{URL: "https://short.example/" + alias, Alias: alias}, nil
+}
+
+func TestSaveKeepsNormalizedUrl(t *testing.T) {
+	saver := newUrlSaverWith(t, rewritingShortener{}, nil)
+	dedupe := true
+
+	urlID, _ := save(t, saver, userID, "HTTPS://Example.com:443/page/?utm_source=mail", "page")
+
+	url, _, _, err := saver.Get(userContext(userID), "page")
+	if err != nil || url != "https://example.com/page" {
+		t.Fatalf("Get() = %q, %v, want the normalized url", url, err)
+	}
+
+	gotID, _, err := saver.Save(userContext(userID), "https://example.com/page", "", entities.UrlMeta{}, &dedupe)
+	if err != nil || gotID != urlID {
+		t.Errorf("Save() with dedupe = %d, %v, want %d", gotID, err, urlID)
+	}
+}
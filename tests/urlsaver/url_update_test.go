@@ -1 +1,120 @@
 package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	urlshortener "github.com/nhassl3/url-saver/internals/clients/urlshortener/http"
+	"github.com/nhassl3/url-saver/internals/domain/entities"
+	"github.com/nhassl3/url-saver/internals/domain/services/urlsaver"
+)
+
+func TestUpdateByID(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   int64
+		url      string
+		alias    string
+		wantErr  error
+		wantURL  string
+		wantGet  string
+		wantGone string
+	}{
+		{name: "new url", userID: userID, url: "https://example.org", wantURL: "https://example.org", wantGet: "old"},
+		{name: "new alias", userID: userID, alias: "new", wantURL: "https://example.com", wantGet: "new", wantGone: "old"},
+		{name: "new url and alias", userID: userID, url: "https://example.org", alias: "new", wantURL: "https://example.org", wantGet: "new", wantGone: "old"},
+		{name: "alias taken", userID: userID, alias: "taken", wantErr: urlsaver.ErrAliasExists},
+		{name: "url of other user", userID: otherUserID, url: "https://example.org", wantErr: urlsaver.ErrAliasNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saver := newUrlSaver(t)
+			urlID, _ := save(t, saver, userID, "https://example.com", "old")
+			save(t, saver, otherUserID, "https://example.net", "taken")
+
+			ok, alias, err := saver.UpdateByID(userContext(tt.userID), urlID, tt.url, tt.alias, entities.UrlMeta{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateByID() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !ok || alias != tt.wantGet {
+				t.Fatalf("UpdateByID() = %t, %q, want alias in effect %q", ok, alias, tt.wantGet)
+			}
+
+			url, _, _, err := saver.Get(userContext(userID), tt.wantGet)
+			if err != nil || url != tt.wantURL {
+				t.Errorf("Get(%q) = %q, %v, want %q", tt.wantGet, url, err, tt.wantURL)
+			}
+			if tt.wantGone != "" {
+				if _, _, _, err = saver.Get(userContext(userID), tt.wantGone); !errors.Is(err, urlsaver.ErrAliasNotFound) {
+					t.Errorf("Get(%q) error = %v, want %v", tt.wantGone, err, urlsaver.ErrAliasNotFound)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateByAlias(t *testing.T) {
+	saver := newUrlSaver(t)
+	save(t, saver, userID, "https://example.com", "old")
+
+	if _, _, err := saver.UpdateByAlias(userContext(userID), "unknown", "https://example.org", "", entities.UrlMeta{}); !errors.Is(err, urlsaver.ErrAliasNotFound) {
+		t.Errorf("UpdateByAlias() of unknown alias error = %v, want %v", err, urlsaver.ErrAliasNotFound)
+	}
+
+	_, alias, err := saver.UpdateByAlias(userContext(userID), "old", "https://example.org", "", entities.UrlMeta{})
+	if err != nil || alias != "old" {
+		t.Fatalf("UpdateByAlias() of url = %q, %v, want the kept alias", alias, err)
+	}
+
+	_, alias, err = saver.UpdateByAlias(userContext(userID), "old", "", "renamed", entities.UrlMeta{})
+	if err != nil || alias != "renamed" {
+		t.Fatalf("UpdateByAlias() = %q, %v, want renamed", alias, err)
+	}
+
+	if url, _, _, err := saver.Get(userContext(userID), "renamed"); err != nil || url != "https://example.org" {
+		t.Errorf("Get() = %q, %v, want the kept url", url, err)
+	}
+}
+
+// recordingShortener keeps urls it was asked to shorten and answers the way
+// the remote shortener does
+type recordingShortener struct {
+	urls []string
+}
+
+func (s *recordingShortener) ShortenURL(_ context.Context, url, alias string) (*urlshortener.ShortenResponse, error) {
+	s.urls = append(s.urls, url)
+	if url == "" {
+		return nil, errors.New("url is required")
+	}
+
+	return &urlshortener.ShortenResponse{URL: url, Alias: alias}, nil
+}
+
+func TestUpdateAliasOnly(t *testing.T) {
+	shortener := &recordingShortener{}
+	saver := newUrlSaverWith(t, shortener, nil)
+	urlID, _ := save(t, saver, userID, "https://example.com", "old")
+	shortener.urls = nil
+
+	_, alias, err := saver.UpdateByID(userContext(userID), urlID, "", "Renamed/", entities.UrlMeta{})
+	if err != nil || alias != "Renamed" {
+		t.Fatalf("UpdateByID() = %q, %v, want normalized alias", alias, err)
+	}
+	// the alias goes through the same pipeline as on Save, for the stored url
+	if len(shortener.urls) != 1 || shortener.urls[0] != "https://example.com" {
+		t.Errorf("shortener asked for %q, want the stored url once", shortener.urls)
+	}
+
+	if _, _, err = saver.UpdateByID(userContext(userID), urlID, "", "admin", entities.UrlMeta{}); !errors.Is(err, urlsaver.ErrAliasReserved) {
+		t.Errorf("UpdateByID() to reserved alias error = %v, want %v", err, urlsaver.ErrAliasReserved)
+	}
+
+	if url, _, _, err := saver.Get(userContext(userID), "Renamed"); err != nil || url != "https://example.com" {
+		t.Errorf("Get() = %q, %v, want the kept url", url, err)
+	}
+}